@@ -0,0 +1,22 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// PersistedQueries stores automatic persisted queries in Redis, so every
+// gateway instance knows a hash once any of them has seen the query. While
+// Redis is down each instance only knows the queries it has seen itself.
+type PersistedQueries struct {
+	TTL time.Duration
+}
+
+func (p PersistedQueries) Get(ctx context.Context, hash string) (string, bool) {
+	query, _, ok := get("apq:" + hash)
+	return query, ok
+}
+
+func (p PersistedQueries) Add(ctx context.Context, hash string, query string) {
+	set("apq:"+hash, query, p.TTL)
+}
@@ -0,0 +1,171 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"graphql-gateway/auth"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// Scope decides who may share a cached response
+type Scope int
+
+const (
+	// Public responses are the same for every caller
+	Public Scope = iota
+	// PerUser responses are cached for each authenticated user separately;
+	// anonymous callers share one entry
+	PerUser
+	// NoCache responses are always fetched
+	NoCache
+)
+
+// Policy is how the responses of a type are cached. Entries are fresh for
+// TTL; for Stale after that they are still served while one caller refreshes
+// them in the background.
+type Policy struct {
+	Scope Scope
+	TTL   time.Duration
+	Stale time.Duration
+}
+
+// PolicyFromEnv returns a policy whose TTLs can be overridden with
+// CACHE_TTL_<name> and CACHE_STALE_TTL_<name>, e.g. CACHE_TTL_PRODUCT=10m. A
+// TTL of 0 disables caching.
+func PolicyFromEnv(name string, scope Scope, ttl, stale time.Duration) Policy {
+	name = strings.ToUpper(name)
+	policy := Policy{
+		Scope: scope,
+		TTL:   getEnvDuration("CACHE_TTL_"+name, ttl),
+		Stale: getEnvDuration("CACHE_STALE_TTL_"+name, stale),
+	}
+	if policy.TTL <= 0 {
+		policy.Scope = NoCache
+	}
+	return policy
+}
+
+// entry is the stored form of a cached response
+type entry struct {
+	Data       json.RawMessage `json:"data"`
+	FreshUntil time.Time       `json:"fresh_until"`
+}
+
+// Fetch returns the response cached under key, calling fetch on a miss and
+// caching its result. Nil results, e.g. for missing entities, are not cached.
+// Cache failures never fail the call; the response is then fetched.
+func Fetch[T any](ctx context.Context, key string, policy Policy, fetch func(context.Context) (T, error)) (T, error) {
+	var result T
+
+	key, ok := scopedKey(ctx, key, policy)
+	if !ok {
+		return fetch(ctx)
+	}
+
+	cachedData, tier, found := get(key)
+	if found {
+		var cached entry
+		if err := json.Unmarshal([]byte(cachedData), &cached); err == nil {
+			if err := json.Unmarshal(cached.Data, &result); err == nil {
+				if time.Now().Before(cached.FreshUntil) {
+					Lookups.WithLabelValues(tier, "hit").Inc()
+					log.Printf("Cache hit: %s", key)
+				} else {
+					Lookups.WithLabelValues(tier, "stale").Inc()
+					log.Printf("Cache hit (stale): %s", key)
+					go refresh(context.WithoutCancel(ctx), key, policy, fetch)
+				}
+				return result, nil
+			}
+		}
+		// Entries from before a format change are fetched again
+		log.Printf("Ignoring unreadable cache entry %s", key)
+	}
+
+	Lookups.WithLabelValues(tier, "miss").Inc()
+	log.Printf("Cache miss: %s", key)
+	result, err := fetch(ctx)
+	if err != nil {
+		return result, err
+	}
+	store(key, policy, result)
+	return result, nil
+}
+
+// refresh fetches a stale entry again. A short lock makes sure only one
+// caller across all gateway instances does so.
+func refresh[T any](ctx context.Context, key string, policy Policy, fetch func(context.Context) (T, error)) {
+	lockKey := key + ":refreshing"
+	if !lock(lockKey, 30*time.Second) {
+		return
+	}
+	defer unlock(lockKey)
+
+	result, err := fetch(ctx)
+	if err != nil {
+		log.Printf("Failed to refresh cache entry %s: %v", key, err)
+		return
+	}
+	store(key, policy, result)
+}
+
+// store caches a response, and records a per-user key under its base key so
+// Invalidate finds it
+func store(key string, policy Policy, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		log.Printf("Failed to marshal %s for caching: %v", key, err)
+		return
+	}
+	if string(data) == "null" {
+		return
+	}
+
+	entryJSON, err := json.Marshal(entry{Data: data, FreshUntil: time.Now().Add(policy.TTL)})
+	if err != nil {
+		log.Printf("Failed to marshal %s for caching: %v", key, err)
+		return
+	}
+	expiration := policy.TTL + policy.Stale
+	set(key, string(entryJSON), expiration)
+
+	if base, _, scoped := strings.Cut(key, scopeSeparator); scoped {
+		addVariant(base, key, expiration)
+	}
+}
+
+// scopeSeparator separates a key from the principal it is scoped to
+const scopeSeparator = "#"
+
+// scopedKey adds the caller to the key of per-user responses. It reports
+// false when the response must not be cached.
+func scopedKey(ctx context.Context, key string, policy Policy) (string, bool) {
+	switch policy.Scope {
+	case Public:
+		return key, true
+	case PerUser:
+		principal := auth.PrincipalFrom(ctx)
+		if principal == nil {
+			return key + scopeSeparator + "anonymous", true
+		}
+		return fmt.Sprintf("%s%suser:%d", key, scopeSeparator, principal.UserID), true
+	}
+	return "", false
+}
+
+// variantsKey is the set of per-user keys cached for a base key
+func variantsKey(key string) string {
+	return "variants:" + key
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
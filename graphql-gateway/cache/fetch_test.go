@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"context"
+	"graphql-gateway/auth"
+	"testing"
+)
+
+func TestScopedKey(t *testing.T) {
+	alice := auth.WithPrincipal(context.Background(), &auth.Principal{UserID: 1, Username: "alice"})
+	bob := auth.WithPrincipal(context.Background(), &auth.Principal{UserID: 2, Username: "bob"})
+	anonymous := context.Background()
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		policy Policy
+		key    string
+		cached bool
+	}{
+		{"public for a user", alice, Policy{Scope: Public}, "products", true},
+		{"public for anonymous", anonymous, Policy{Scope: Public}, "products", true},
+		{"per user", alice, Policy{Scope: PerUser}, "orders#user:1", true},
+		{"per user for another user", bob, Policy{Scope: PerUser}, "orders#user:2", true},
+		{"per user for anonymous", anonymous, Policy{Scope: PerUser}, "orders#anonymous", true},
+		{"no cache", alice, Policy{Scope: NoCache}, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := "products"
+			if tt.policy.Scope != Public {
+				base = "orders"
+			}
+			key, cached := scopedKey(tt.ctx, base, tt.policy)
+			if key != tt.key || cached != tt.cached {
+				t.Errorf("scopedKey = %q, %v, want %q, %v", key, cached, tt.key, tt.cached)
+			}
+		})
+	}
+}
+
+func TestScopedKeySeparatesUsers(t *testing.T) {
+	policy := Policy{Scope: PerUser}
+	keys := map[string]*auth.Principal{}
+	for _, principal := range []*auth.Principal{nil, {UserID: 1}, {UserID: 2}, {UserID: 12}} {
+		ctx := context.Background()
+		if principal != nil {
+			ctx = auth.WithPrincipal(ctx, principal)
+		}
+		key, _ := scopedKey(ctx, "orders", policy)
+		if other, taken := keys[key]; taken {
+			t.Fatalf("key %q is shared by %v and %v", key, other, principal)
+		}
+		keys[key] = principal
+	}
+}
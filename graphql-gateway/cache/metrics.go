@@ -0,0 +1,30 @@
+package cache
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var Lookups = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "graphql_gateway_cache_lookups_total",
+		Help: "Cache lookups by tier and result (hit, stale or miss)",
+	},
+	[]string{"tier", "result"},
+)
+
+var Errors = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "graphql_gateway_cache_errors_total",
+		Help: "Failed Redis operations by operation",
+	},
+	[]string{"operation"},
+)
+
+var RedisUp = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "graphql_gateway_redis_up",
+		Help: "Whether Redis answered the last health check",
+	},
+)
+
+func registerMetrics() {
+	prometheus.MustRegister(Lookups, Errors, RedisUp)
+}
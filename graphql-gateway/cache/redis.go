@@ -2,13 +2,30 @@ package cache
 
 import (
 	"fmt"
+	"log"
 	"os"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-redis/redis"
 )
 
 var RedisClient *redis.Client
 
+// redisUp is whether Redis answered the last health check. While it is down
+// the cache skips Redis and uses the in-process tier only.
+var redisUp atomic.Bool
+
+// pendingInvalidations are the keys invalidated while Redis was down; they
+// are evicted from Redis once it is back, as its entries may be outdated
+var pendingInvalidations = struct {
+	sync.Mutex
+	keys map[string]bool
+}{keys: map[string]bool{}}
+
+// InitRedis connects to Redis. The gateway starts even if Redis is down; a
+// background health check reconnects once it is available.
 func InitRedis() {
 	redisHost := os.Getenv("REDIS_HOST")
 	if redisHost == "" {
@@ -26,12 +43,108 @@ func InitRedis() {
 		Addr:     redisAddr,
 		Password: "", // No password by default
 		DB:       0,  // Use default DB
+		// Fail fast, so a slow Redis does not hold up queries
+		DialTimeout:  500 * time.Millisecond,
+		ReadTimeout:  500 * time.Millisecond,
+		WriteTimeout: 500 * time.Millisecond,
 	})
 
+	registerMetrics()
+
 	// Test the connection
-	_, err := RedisClient.Ping().Result()
-	if err != nil {
-		panic(fmt.Sprintf("Failed to connect to Redis: %v", err))
+	checkRedis()
+	if !redisUp.Load() {
+		log.Println("Starting without Redis; caching in memory until it is available")
+	}
+	go monitorRedis(getEnvDuration("REDIS_HEALTH_CHECK_INTERVAL", 5*time.Second))
+}
+
+// monitorRedis pings Redis at the given interval
+func monitorRedis(interval time.Duration) {
+	for range time.Tick(interval) {
+		checkRedis()
+	}
+}
+
+func checkRedis() {
+	if err := RedisClient.Ping().Err(); err != nil {
+		markRedisDown(err)
+		return
+	}
+	if !redisUp.Swap(true) {
+		RedisUp.Set(1)
+		log.Println("Connected to Redis successfully")
+		replayInvalidations()
+	}
+}
+
+// markRedisDown switches the cache to the in-process tier until the next
+// successful health check
+func markRedisDown(err error) {
+	if redisUp.Swap(false) {
+		log.Printf("Redis unavailable, caching in memory: %v", err)
+	}
+	RedisUp.Set(0)
+}
+
+// Helper function to evict from Redis what was invalidated during an outage
+func replayInvalidations() {
+	pendingInvalidations.Lock()
+	keys := make([]string, 0, len(pendingInvalidations.keys))
+	for key := range pendingInvalidations.keys {
+		keys = append(keys, key)
+	}
+	pendingInvalidations.keys = map[string]bool{}
+	pendingInvalidations.Unlock()
+
+	if len(keys) > 0 {
+		invalidateRedis(keys)
+	}
+}
+
+// Invalidate evicts the given keys, including their per-user variants.
+// Failures are only logged: the entries still expire with their TTL.
+func Invalidate(keys ...string) {
+	invalidateLocal(keys)
+	if !redisUp.Load() {
+		pendingInvalidations.Lock()
+		for _, key := range keys {
+			pendingInvalidations.keys[key] = true
+		}
+		pendingInvalidations.Unlock()
+		log.Printf("Invalidated cache keys %v in memory; Redis follows when it is back", keys)
+		return
 	}
-	fmt.Println("Connected to Redis successfully")
+	invalidateRedis(keys)
+}
+
+func invalidateRedis(keys []string) {
+	evicted := append([]string{}, keys...)
+	for _, key := range keys {
+		variants, err := RedisClient.SMembers(variantsKey(key)).Result()
+		if err != nil {
+			log.Printf("Failed to list cache variants of %s: %v", key, err)
+			continue
+		}
+		evicted = append(evicted, variants...)
+		evicted = append(evicted, variantsKey(key))
+	}
+
+	if err := RedisClient.Del(evicted...).Err(); err != nil {
+		Errors.WithLabelValues("invalidate").Inc()
+		log.Printf("Failed to invalidate cache keys %v: %v", keys, err)
+		return
+	}
+	log.Printf("Invalidated cache keys %v", keys)
+}
+
+// ProductKeys returns the keys holding the given product, including the
+// product list
+func ProductKeys(productID int) []string {
+	return []string{"products", fmt.Sprintf("product:%d", productID)}
+}
+
+// UserKeys returns the keys holding the given user, including the user list
+func UserKeys(userID int) []string {
+	return []string{"users", fmt.Sprintf("user:%d", userID)}
 }
@@ -0,0 +1,136 @@
+package cache
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+// Cache tiers, as reported in the metrics
+const (
+	tierRedis = "redis"
+	tierLocal = "local"
+)
+
+// localEntry is a value of the in-process tier with its expiry
+type localEntry struct {
+	value     string
+	expiresAt time.Time
+}
+
+// local is the in-process tier. It is written alongside Redis so it is warm
+// when Redis goes down, and only read while Redis is unavailable.
+var local = newLocalCache(getEnvInt("CACHE_LOCAL_SIZE", 1000))
+
+// localLocks are the refresh locks taken while Redis is down
+var localLocks sync.Map
+
+func newLocalCache(size int) *lru.Cache[string, localEntry] {
+	cache, err := lru.New[string, localEntry](size)
+	if err != nil {
+		log.Fatalf("Failed to create local cache: %v", err)
+	}
+	return cache
+}
+
+// get reads a key from Redis, or from the in-process tier while Redis is
+// down. Redis errors are treated as misses, so callers fall through to the
+// services. It returns the tier that was read.
+func get(key string) (string, string, bool) {
+	if redisUp.Load() {
+		value, err := RedisClient.Get(key).Result()
+		if err == nil {
+			return value, tierRedis, true
+		}
+		if err == redis.Nil {
+			return "", tierRedis, false
+		}
+		Errors.WithLabelValues("get").Inc()
+		markRedisDown(err)
+	}
+
+	entry, ok := local.Get(key)
+	if !ok || time.Now().After(entry.expiresAt) {
+		return "", tierLocal, false
+	}
+	return entry.value, tierLocal, true
+}
+
+// set writes a key to both tiers; Redis failures are only logged
+func set(key, value string, expiration time.Duration) {
+	local.Add(key, localEntry{value: value, expiresAt: time.Now().Add(expiration)})
+	if !redisUp.Load() {
+		return
+	}
+	if err := RedisClient.Set(key, value, expiration).Err(); err != nil {
+		Errors.WithLabelValues("set").Inc()
+		markRedisDown(err)
+	}
+}
+
+// addVariant records a per-user key under its base key in Redis; the
+// in-process tier finds variants by prefix instead
+func addVariant(base, key string, expiration time.Duration) {
+	if !redisUp.Load() {
+		return
+	}
+	variants := variantsKey(base)
+	if err := RedisClient.SAdd(variants, key).Err(); err != nil {
+		Errors.WithLabelValues("set").Inc()
+		markRedisDown(err)
+		return
+	}
+	RedisClient.Expire(variants, expiration)
+}
+
+// lock takes a lock for the given time, across all gateway instances while
+// Redis is up and within this one otherwise. It reports false if the lock
+// is already held.
+func lock(key string, expiration time.Duration) bool {
+	if redisUp.Load() {
+		locked, err := RedisClient.SetNX(key, 1, expiration).Result()
+		if err == nil {
+			return locked
+		}
+		Errors.WithLabelValues("lock").Inc()
+		markRedisDown(err)
+	}
+	_, held := localLocks.LoadOrStore(key, true)
+	return !held
+}
+
+func unlock(key string) {
+	localLocks.Delete(key)
+	if redisUp.Load() {
+		RedisClient.Del(key)
+	}
+}
+
+// invalidateLocal evicts keys and their per-user variants from the
+// in-process tier
+func invalidateLocal(keys []string) {
+	for _, key := range keys {
+		local.Remove(key)
+	}
+	for _, cached := range local.Keys() {
+		for _, key := range keys {
+			if strings.HasPrefix(cached, key+scopeSeparator) {
+				local.Remove(cached)
+			}
+		}
+	}
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,69 @@
+package clients
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Breaker is a consecutive-failure circuit breaker. After Threshold failures
+// in a row it opens and rejects calls for Cooldown; then a single trial call
+// is let through, which closes it again on success.
+type Breaker struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	trial     bool
+}
+
+// Allow reports whether a call may be made. Every allowed call must be
+// followed by Success, Failure or Release.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.Threshold {
+		return true
+	}
+	if time.Now().Before(b.openUntil) || b.trial {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures >= b.Threshold {
+		log.Println("Circuit breaker closed")
+	}
+	b.failures = 0
+	b.trial = false
+}
+
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.trial = false
+	if b.failures >= b.Threshold {
+		if b.failures == b.Threshold {
+			log.Printf("Circuit breaker opened after %d failures", b.failures)
+		}
+		b.openUntil = time.Now().Add(b.Cooldown)
+	}
+}
+
+// Release ends a call without a verdict, e.g. when the caller cancelled it
+func (b *Breaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+}
@@ -0,0 +1,84 @@
+package clients
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	// Each step is an event and, for "allow", whether the call is let through
+	type step struct {
+		event string
+		allow bool
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "closed below the threshold",
+			steps: []step{
+				{"failure", false}, {"failure", false}, {"allow", true},
+				{"success", false}, {"failure", false}, {"failure", false}, {"allow", true},
+			},
+		},
+		{
+			name: "opens at the threshold",
+			steps: []step{
+				{"failure", false}, {"failure", false}, {"failure", false}, {"allow", false}, {"allow", false},
+			},
+		},
+		{
+			name: "lets one trial call through after the cooldown",
+			steps: []step{
+				{"failure", false}, {"failure", false}, {"failure", false},
+				{"cooldown", false}, {"allow", true}, {"allow", false},
+			},
+		},
+		{
+			name: "closes when the trial call succeeds",
+			steps: []step{
+				{"failure", false}, {"failure", false}, {"failure", false},
+				{"cooldown", false}, {"allow", true}, {"success", false}, {"allow", true}, {"allow", true},
+			},
+		},
+		{
+			name: "reopens when the trial call fails",
+			steps: []step{
+				{"failure", false}, {"failure", false}, {"failure", false},
+				{"cooldown", false}, {"allow", true}, {"failure", false}, {"allow", false},
+				{"cooldown", false}, {"allow", true},
+			},
+		},
+		{
+			name: "released trial call makes room for another",
+			steps: []step{
+				{"failure", false}, {"failure", false}, {"failure", false},
+				{"cooldown", false}, {"allow", true}, {"release", false}, {"allow", true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			breaker := &Breaker{Threshold: 3, Cooldown: time.Hour}
+			for i, s := range tt.steps {
+				switch s.event {
+				case "allow":
+					if allowed := breaker.Allow(); allowed != s.allow {
+						t.Fatalf("step %d: allow = %v, want %v", i+1, allowed, s.allow)
+					}
+				case "success":
+					breaker.Success()
+				case "failure":
+					breaker.Failure()
+				case "release":
+					breaker.Release()
+				case "cooldown":
+					breaker.openUntil = time.Now().Add(-time.Millisecond)
+				}
+			}
+		})
+	}
+}
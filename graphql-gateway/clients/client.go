@@ -0,0 +1,264 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"graphql-gateway/auth"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// Error is a failed call to a service. StatusCode is 0 when no response was
+// received, e.g. on timeouts or while the circuit breaker is open.
+type Error struct {
+	Service    string
+	StatusCode int
+	Message    string
+	// Body is the raw response, for endpoints that return data with an error
+	// status
+	Body []byte
+}
+
+func (e *Error) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("%s unavailable: %s", e.Service, e.Message)
+	}
+	return fmt.Sprintf("%s returned %d: %s", e.Service, e.StatusCode, e.Message)
+}
+
+// IsNotFound reports whether err is a 404 from a service
+func IsNotFound(err error) bool {
+	var serviceErr *Error
+	return errors.As(err, &serviceErr) && serviceErr.StatusCode == http.StatusNotFound
+}
+
+// Client calls one downstream service with a shared timeout, retries for
+// idempotent requests and a circuit breaker
+type Client struct {
+	Name       string
+	BaseURL    string
+	HTTPClient *http.Client
+	// Retries is the number of extra attempts for idempotent requests
+	Retries int
+	breaker *Breaker
+}
+
+// All clients share one transport so connections are pooled across services
+var httpClient = &http.Client{Timeout: getEnvDuration("DOWNSTREAM_TIMEOUT", 5*time.Second)}
+
+func NewClient(name, baseURL string) *Client {
+	return &Client{
+		Name:       name,
+		BaseURL:    baseURL,
+		HTTPClient: httpClient,
+		Retries:    getEnvInt("DOWNSTREAM_RETRIES", 2),
+		breaker: &Breaker{
+			Threshold: getEnvInt("CIRCUIT_BREAKER_THRESHOLD", 5),
+			Cooldown:  getEnvDuration("CIRCUIT_BREAKER_COOLDOWN", 30*time.Second),
+		},
+	}
+}
+
+// Request describes one call. Body is encoded as JSON. Authenticated requests
+// fail with auth.Unauthenticated, without calling the service, when the
+// caller is anonymous; the caller's token is forwarded whenever there is one.
+type Request struct {
+	Method        string
+	Path          string
+	Query         url.Values
+	Body          interface{}
+	Header        http.Header
+	Authenticated bool
+	// Idempotent forwards the client's Idempotency-Key, which also makes the
+	// request safe to retry
+	Idempotent bool
+}
+
+// Do sends the request and decodes a successful JSON response into out,
+// which may be nil. Other responses are returned as *Error.
+func (c *Client) Do(ctx context.Context, req Request, out interface{}) error {
+	var payload []byte
+	if req.Body != nil {
+		var err error
+		payload, err = json.Marshal(req.Body)
+		if err != nil {
+			return err
+		}
+	}
+
+	header := http.Header{}
+	for key, values := range req.Header {
+		header[key] = values
+	}
+	if payload != nil {
+		header.Set("Content-Type", "application/json")
+	}
+	if authHeader := auth.AuthHeader(ctx); authHeader != "" {
+		header.Set("Authorization", authHeader)
+	} else if req.Authenticated {
+		return auth.Unauthenticated(ctx)
+	}
+	retryable := req.Method == http.MethodGet || req.Method == http.MethodPut || req.Method == http.MethodDelete
+	if req.Idempotent {
+		if key := idempotencyKey(ctx); key != "" {
+			header.Set("Idempotency-Key", key)
+			retryable = true
+		}
+	}
+
+	endpoint := c.BaseURL + req.Path
+	if len(req.Query) > 0 {
+		endpoint += "?" + req.Query.Encode()
+	}
+
+	attempts := 1
+	if retryable {
+		attempts += c.Retries
+	}
+
+	var body []byte
+	var status int
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			// Back off 100ms, 200ms, 400ms, ... between attempts
+			select {
+			case <-ctx.Done():
+				return &Error{Service: c.Name, Message: ctx.Err().Error()}
+			case <-time.After(100 * time.Millisecond << (attempt - 1)):
+			}
+			log.Printf("Retrying %s %s (attempt %d of %d)", req.Method, endpoint, attempt+1, attempts)
+		}
+
+		if !c.breaker.Allow() {
+			return &Error{Service: c.Name, Message: "circuit breaker open"}
+		}
+		body, status, err = c.send(ctx, req.Method, endpoint, header, payload)
+		switch {
+		case err != nil && ctx.Err() != nil:
+			// The caller gave up; that says nothing about the service
+			c.breaker.Release()
+		case err != nil || status >= http.StatusInternalServerError:
+			c.breaker.Failure()
+		default:
+			c.breaker.Success()
+		}
+
+		if err == nil && !retryableStatus(status) {
+			break
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if err != nil {
+		return &Error{Service: c.Name, Message: err.Error()}
+	}
+
+	if status < 200 || status >= 300 {
+		var result struct {
+			Error string `json:"error"`
+		}
+		json.Unmarshal(body, &result)
+		if result.Error == "" {
+			result.Error = http.StatusText(status)
+		}
+		return &Error{Service: c.Name, StatusCode: status, Message: result.Error, Body: body}
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("unexpected response from %s: %v", c.Name, err)
+	}
+	return nil
+}
+
+// Helper function to send a single attempt and read the whole response
+func (c *Client) send(ctx context.Context, method, endpoint string, header http.Header, payload []byte) ([]byte, int, error) {
+	var reqBody io.Reader
+	if payload != nil {
+		reqBody = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reqBody)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header = header.Clone()
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	return body, resp.StatusCode, nil
+}
+
+// retryableStatus reports whether a response means the service, or a proxy
+// in front of it, could not handle the request right now
+func retryableStatus(status int) bool {
+	return status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+}
+
+type idempotencyKeyContextKey struct{}
+
+// WithIdempotencyKey returns a context carrying the client's Idempotency-Key,
+// which is forwarded on idempotent requests
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyContextKey{}, key)
+}
+
+// Helper function to get the client's Idempotency-Key for a service call. The
+// key is suffixed with the path of the mutation field, so several mutations
+// in one request each get their own key while retries map to the same one.
+func idempotencyKey(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKeyContextKey{}).(string)
+	if key == "" {
+		return ""
+	}
+	if fieldContext := graphql.GetFieldContext(ctx); fieldContext != nil {
+		key = key + ":" + fieldContext.Path().String()
+	}
+	return key
+}
+
+func getEnv(key, defaultValue string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,154 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"graphql-gateway/graph/model"
+	"net/http"
+	"net/url"
+)
+
+type Order struct {
+	ID              int                    `json:"id"`
+	UserID          int                    `json:"user_id"`
+	Status          string                 `json:"status"`
+	Subtotal        float64                `json:"subtotal"`
+	DiscountTotal   float64                `json:"discount_total"`
+	TaxTotal        float64                `json:"tax_total"`
+	ShippingTotal   float64                `json:"shipping_total"`
+	Total           float64                `json:"total"`
+	CouponCode      *string                `json:"coupon_code"`
+	FreeShipping    bool                   `json:"free_shipping"`
+	CreatedAt       string                 `json:"created_at"`
+	CancelledAt     *string                `json:"cancelled_at"`
+	CancelReason    *string                `json:"cancel_reason"`
+	ShippingAddress *model.ShippingAddress `json:"shipping_address"`
+	Items           []OrderItem            `json:"items"`
+	Shipments       []Shipment             `json:"shipments"`
+}
+
+type OrderItem struct {
+	ID        int     `json:"id"`
+	OrderID   int     `json:"order_id"`
+	ProductID int     `json:"product_id"`
+	Quantity  int     `json:"quantity"`
+	Price     float64 `json:"price"`
+	Discount  float64 `json:"discount"`
+	Tax       float64 `json:"tax"`
+}
+
+type Shipment struct {
+	ID             int                  `json:"id"`
+	Carrier        string               `json:"carrier"`
+	TrackingNumber string               `json:"tracking_number"`
+	Status         string               `json:"status"`
+	ShippedAt      string               `json:"shipped_at"`
+	DeliveredAt    *string              `json:"delivered_at"`
+	Items          []model.ShipmentItem `json:"items"`
+}
+
+// OrderPage is a page of the caller's orders
+type OrderPage struct {
+	Orders     []Order `json:"orders"`
+	NextCursor *string `json:"next_cursor"`
+	HasMore    bool    `json:"has_more"`
+}
+
+// AdminOrderPage is a page of orders across all users
+type AdminOrderPage struct {
+	Orders     []Order `json:"orders"`
+	Page       int     `json:"page"`
+	PageSize   int     `json:"page_size"`
+	TotalCount int     `json:"total_count"`
+}
+
+// OrderResult is the reply to placing or cancelling an order
+type OrderResult struct {
+	Message        string  `json:"message"`
+	OrderID        int     `json:"order_id"`
+	RefundedAmount float64 `json:"refunded_amount"`
+}
+
+// OrderClient calls the Order Service. Quotes and carts are decoded straight
+// into the GraphQL models, which mirror the service's responses.
+type OrderClient struct {
+	*Client
+}
+
+var Orders = &OrderClient{NewClient("order-service", getEnv("ORDER_SERVICE_URL", "http://order-service:8083"))}
+
+// List returns a page of the caller's orders matching the filters in query
+func (c *OrderClient) List(ctx context.Context, query url.Values) (*OrderPage, error) {
+	page := &OrderPage{}
+	err := c.Do(ctx, Request{Method: http.MethodGet, Path: "/orders", Query: query, Authenticated: true}, page)
+	return page, err
+}
+
+// AdminList returns a page of all orders matching the filters in query
+func (c *OrderClient) AdminList(ctx context.Context, query url.Values) (*AdminOrderPage, error) {
+	page := &AdminOrderPage{}
+	err := c.Do(ctx, Request{Method: http.MethodGet, Path: "/admin/orders", Query: query, Authenticated: true}, page)
+	return page, err
+}
+
+func (c *OrderClient) Get(ctx context.Context, id string) (*Order, error) {
+	var result struct {
+		Order *Order `json:"order"`
+	}
+	err := c.Do(ctx, Request{Method: http.MethodGet, Path: "/orders/" + url.PathEscape(id), Authenticated: true}, &result)
+	return result.Order, err
+}
+
+func (c *OrderClient) Cancel(ctx context.Context, id string, reason *string) (*OrderResult, error) {
+	result := &OrderResult{}
+	err := c.Do(ctx, Request{
+		Method:        http.MethodPost,
+		Path:          "/orders/" + url.PathEscape(id) + "/cancel",
+		Body:          map[string]interface{}{"reason": reason},
+		Authenticated: true,
+	}, result)
+	return result, err
+}
+
+func (c *OrderClient) Place(ctx context.Context, input interface{}) (*OrderResult, error) {
+	result := &OrderResult{}
+	err := c.Do(ctx, Request{Method: http.MethodPost, Path: "/orders", Body: input, Authenticated: true, Idempotent: true}, result)
+	return result, err
+}
+
+// Quote prices an order without placing it
+func (c *OrderClient) Quote(ctx context.Context, input interface{}) (*model.PriceQuote, error) {
+	var result struct {
+		Quote *model.PriceQuote `json:"quote"`
+	}
+	err := c.Do(ctx, Request{Method: http.MethodPost, Path: "/orders/quote", Body: input, Authenticated: true}, &result)
+	if err == nil && result.Quote == nil {
+		return nil, errors.New("unexpected response from order-service: no quote")
+	}
+	return result.Quote, err
+}
+
+// Checkout places an order for the caller's cart
+func (c *OrderClient) Checkout(ctx context.Context, input interface{}) (*OrderResult, error) {
+	result := &OrderResult{}
+	err := c.Do(ctx, Request{Method: http.MethodPost, Path: "/cart/checkout", Body: input, Authenticated: true, Idempotent: true}, result)
+	return result, err
+}
+
+// Cart calls a cart endpoint. Guest carts are addressed with the X-Cart-ID
+// header, signed-in users by their token.
+func (c *OrderClient) Cart(ctx context.Context, method, path string, cartID *string, input interface{}) (*model.Cart, error) {
+	header := http.Header{}
+	if cartID != nil && *cartID != "" {
+		header.Set("X-Cart-ID", *cartID)
+	}
+
+	var result struct {
+		Cart *model.Cart `json:"cart"`
+	}
+	err := c.Do(ctx, Request{Method: method, Path: path, Body: input, Header: header}, &result)
+	if err == nil && result.Cart == nil {
+		return nil, errors.New("unexpected response from order-service: no cart")
+	}
+	return result.Cart, err
+}
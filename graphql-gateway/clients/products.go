@@ -0,0 +1,112 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+)
+
+type Product struct {
+	ID          int            `json:"id"`
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Price       float64        `json:"price"`
+	Category    string         `json:"category"`
+	TaxClass    string         `json:"tax_class"`
+	WeightGrams int            `json:"weight_grams"`
+	Inventory   int            `json:"inventory"`
+	Version     int            `json:"version"`
+	CreatedAt   string         `json:"created_at"`
+	ArchivedAt  *string        `json:"archived_at"`
+	Images      []ProductImage `json:"images"`
+}
+
+type ProductImage struct {
+	ID           int    `json:"id"`
+	Position     int    `json:"position"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	ContentType  string `json:"content_type"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+}
+
+// ProductClient calls the Product Service
+type ProductClient struct {
+	*Client
+}
+
+var Products = &ProductClient{NewClient("product-service", getEnv("PRODUCT_SERVICE_URL", "http://product-service:8082"))}
+
+// List returns the active products, or the products with the given IDs,
+// archived ones included, when there are any
+func (c *ProductClient) List(ctx context.Context, ids []int) ([]Product, error) {
+	var query url.Values
+	if len(ids) > 0 {
+		query = url.Values{"ids": {joinIDs(ids)}}
+	}
+
+	var result struct {
+		Products []Product `json:"products"`
+	}
+	err := c.Do(ctx, Request{Method: http.MethodGet, Path: "/products", Query: query}, &result)
+	return result.Products, err
+}
+
+// Get returns a product, including an archived one
+func (c *ProductClient) Get(ctx context.Context, id string) (*Product, error) {
+	var result struct {
+		Product *Product `json:"product"`
+	}
+	err := c.Do(ctx, Request{Method: http.MethodGet, Path: "/products/" + url.PathEscape(id)}, &result)
+
+	// Archived products answer 410 but are still returned in the body
+	var serviceErr *Error
+	if errors.As(err, &serviceErr) && serviceErr.StatusCode == http.StatusGone {
+		if json.Unmarshal(serviceErr.Body, &result) == nil && result.Product != nil {
+			return result.Product, nil
+		}
+	}
+	return result.Product, err
+}
+
+// Create adds a product and returns the service's message
+func (c *ProductClient) Create(ctx context.Context, input interface{}) (string, error) {
+	var result struct {
+		Message string `json:"message"`
+	}
+	err := c.Do(ctx, Request{Method: http.MethodPost, Path: "/products", Body: input, Authenticated: true, Idempotent: true}, &result)
+	return result.Message, err
+}
+
+// Update changes the given fields of a product
+func (c *ProductClient) Update(ctx context.Context, id string, input interface{}) (string, *Product, error) {
+	return c.mutate(ctx, http.MethodPatch, id, input)
+}
+
+// Delete archives a product
+func (c *ProductClient) Delete(ctx context.Context, id string) (string, *Product, error) {
+	return c.mutate(ctx, http.MethodDelete, id, nil)
+}
+
+// Helper function to send an admin request for a single product that returns
+// the product
+func (c *ProductClient) mutate(ctx context.Context, method, id string, input interface{}) (string, *Product, error) {
+	var result struct {
+		Message string   `json:"message"`
+		Product *Product `json:"product"`
+	}
+	err := c.Do(ctx, Request{
+		Method:        method,
+		Path:          "/products/" + url.PathEscape(id),
+		Body:          input,
+		Authenticated: true,
+		Idempotent:    true,
+	}, &result)
+	if err == nil && result.Product == nil {
+		return "", nil, errors.New("unexpected response from product-service: no product")
+	}
+	return result.Message, result.Product, err
+}
@@ -0,0 +1,83 @@
+package clients
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+type User struct {
+	ID        int    `json:"id"`
+	Username  string `json:"username"`
+	Email     string `json:"email"`
+	CreatedAt string `json:"created_at"`
+}
+
+// UserClient calls the User Service
+type UserClient struct {
+	*Client
+}
+
+var Users = &UserClient{NewClient("user-service", getEnv("USER_SERVICE_URL", "http://user-service:8081"))}
+
+// List returns all users, or only those with the given IDs when there are any
+func (c *UserClient) List(ctx context.Context, ids []int) ([]User, error) {
+	var query url.Values
+	if len(ids) > 0 {
+		query = url.Values{"ids": {joinIDs(ids)}}
+	}
+
+	var result struct {
+		Users []User `json:"users"`
+	}
+	err := c.Do(ctx, Request{Method: http.MethodGet, Path: "/users", Query: query}, &result)
+	return result.Users, err
+}
+
+func (c *UserClient) Get(ctx context.Context, id string) (*User, error) {
+	var result struct {
+		User *User `json:"user"`
+	}
+	err := c.Do(ctx, Request{Method: http.MethodGet, Path: "/users/" + url.PathEscape(id)}, &result)
+	return result.User, err
+}
+
+// Register creates a user and returns the service's message
+func (c *UserClient) Register(ctx context.Context, input interface{}) (string, error) {
+	var result struct {
+		Message string `json:"message"`
+	}
+	err := c.Do(ctx, Request{Method: http.MethodPost, Path: "/register", Body: input, Idempotent: true}, &result)
+	return result.Message, err
+}
+
+// Login returns a token for the user along with the user
+func (c *UserClient) Login(ctx context.Context, input interface{}) (string, *User, error) {
+	var result struct {
+		Token string `json:"token"`
+		User  *User  `json:"user"`
+	}
+	err := c.Do(ctx, Request{Method: http.MethodPost, Path: "/login", Body: input}, &result)
+	return result.Token, result.User, err
+}
+
+// UpdateProfile changes the caller's profile and returns the updated user
+func (c *UserClient) UpdateProfile(ctx context.Context, input interface{}) (string, *User, error) {
+	var result struct {
+		Message string `json:"message"`
+		User    *User  `json:"user"`
+	}
+	err := c.Do(ctx, Request{Method: http.MethodPut, Path: "/profile", Body: input, Authenticated: true}, &result)
+	return result.Message, result.User, err
+}
+
+// Helper function to format IDs for the batch endpoints
+func joinIDs(ids []int) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.Itoa(id)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,112 @@
+package events
+
+import (
+	"fmt"
+	"log"
+	"sync"
+)
+
+// subscriptionEvents are the routing keys forwarded to GraphQL subscriptions
+var subscriptionEvents = []string{
+	"order_shipped",
+	"order_cancelled",
+	"payment_succeeded",
+	"payment_failed",
+	"inventory_updated",
+}
+
+// Event is a message received from the events exchange
+type Event struct {
+	RoutingKey string
+	Body       []byte
+}
+
+var (
+	subscribersMu sync.Mutex
+	subscribers   = map[string]map[chan Event]struct{}{}
+)
+
+// Subscribe returns a channel receiving the events with the given routing
+// keys. The caller must call the returned function once it stops reading.
+// It fails with ErrUnavailable while RabbitMQ is not connected.
+func Subscribe(routingKeys ...string) (<-chan Event, func(), error) {
+	if !connected.Load() {
+		return nil, nil, ErrUnavailable
+	}
+	ch := make(chan Event, 16)
+
+	subscribersMu.Lock()
+	for _, routingKey := range routingKeys {
+		if subscribers[routingKey] == nil {
+			subscribers[routingKey] = map[chan Event]struct{}{}
+		}
+		subscribers[routingKey][ch] = struct{}{}
+	}
+	subscribersMu.Unlock()
+
+	unsubscribe := func() {
+		subscribersMu.Lock()
+		for _, routingKey := range routingKeys {
+			delete(subscribers[routingKey], ch)
+		}
+		subscribersMu.Unlock()
+	}
+	return ch, unsubscribe, nil
+}
+
+// broadcast hands the event to every subscriber of its routing key. A
+// subscriber that falls behind misses events rather than stalling the others.
+func broadcast(event Event) {
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+
+	for ch := range subscribers[event.RoutingKey] {
+		select {
+		case ch <- event:
+		default:
+			log.Printf("Dropping %s event for a slow subscriber", event.RoutingKey)
+		}
+	}
+}
+
+// listenForSubscriptions feeds order and inventory events to the GraphQL
+// subscriptions of this gateway instance through its own exclusive queue
+func listenForSubscriptions() error {
+	queue, err := Channel.QueueDeclare(
+		"",    // server-named
+		false, // durable
+		true,  // autoDelete
+		true,  // exclusive
+		false, // noWait
+		nil,   // args
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare subscription queue: %w", err)
+	}
+
+	for _, routingKey := range subscriptionEvents {
+		if err := Channel.QueueBind(queue.Name, routingKey, Exchange, false, nil); err != nil {
+			return fmt.Errorf("failed to bind subscription queue to %s: %w", routingKey, err)
+		}
+	}
+
+	msgs, err := Channel.Consume(
+		queue.Name, // queue
+		"",         // consumer
+		true,       // auto-ack
+		true,       // exclusive
+		false,      // no-local
+		false,      // no-wait
+		nil,        // args
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register consumer for subscriptions: %w", err)
+	}
+
+	go func() {
+		for d := range msgs {
+			broadcast(Event{RoutingKey: d.RoutingKey, Body: d.Body})
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,80 @@
+package extensions
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+const errQueryNotAllowedCode = "PERSISTED_QUERY_NOT_ALLOWED"
+
+// AllowList only runs registered queries. Clients send either the
+// sha256Hash of a registered query in the persistedQuery extension, as with
+// automatic persisted queries, or the full text of a registered query.
+type AllowList struct {
+	// Queries maps the hex encoded sha256 of each registered query to its text
+	Queries map[string]string
+}
+
+var _ interface {
+	graphql.OperationParameterMutator
+	graphql.HandlerExtension
+} = AllowList{}
+
+// LoadAllowList reads a JSON manifest of registered queries, an object
+// mapping each query's sha256 hash to its text. Entries whose hash does not
+// match their query are rejected so the manifest cannot drift.
+func LoadAllowList(path string) (AllowList, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return AllowList{}, err
+	}
+
+	queries := map[string]string{}
+	if err := json.Unmarshal(data, &queries); err != nil {
+		return AllowList{}, fmt.Errorf("invalid persisted query manifest: %v", err)
+	}
+	for hash, query := range queries {
+		if queryHash(query) != hash {
+			return AllowList{}, fmt.Errorf("persisted query %s does not match its hash", hash)
+		}
+	}
+	return AllowList{Queries: queries}, nil
+}
+
+func (a AllowList) ExtensionName() string {
+	return "AllowList"
+}
+
+func (a AllowList) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (a AllowList) MutateOperationParameters(ctx context.Context, rawParams *graphql.RawParams) *gqlerror.Error {
+	hash := queryHash(rawParams.Query)
+	if rawParams.Query == "" {
+		persistedQuery, _ := rawParams.Extensions["persistedQuery"].(map[string]interface{})
+		hash, _ = persistedQuery["sha256Hash"].(string)
+	}
+
+	query, ok := a.Queries[hash]
+	if !ok {
+		err := gqlerror.Errorf("query is not in the allow-list")
+		errcode.Set(err, errQueryNotAllowedCode)
+		return err
+	}
+	rawParams.Query = query
+	return nil
+}
+
+func queryHash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,61 @@
+package extensions
+
+import (
+	"context"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+const depthTestSchema = `
+type Query {
+	user(id: ID!): User
+}
+
+type User {
+	id: ID!
+	name: String!
+	friends: [User!]!
+}
+`
+
+func TestDepthLimit(t *testing.T) {
+	schema := gqlparser.MustLoadSchema(&ast.Source{Name: "schema.graphql", Input: depthTestSchema})
+
+	tests := []struct {
+		name  string
+		query string
+		depth int
+	}{
+		{"single field", `{ user(id: 1) { id } }`, 2},
+		{"nested fields", `{ user(id: 1) { friends { friends { name } } } }`, 4},
+		{"deepest branch counts", `{ user(id: 1) { id friends { name } } }`, 3},
+		{"inline fragment adds no depth", `{ user(id: 1) { ... on User { friends { name } } } }`, 3},
+		{"fragment spread counts its fields", `{ user(id: 1) { ...Friends } } fragment Friends on User { friends { friends { name } } }`, 4},
+		{"introspection is not counted", `{ __schema { types { fields { type { name } } } } user(id: 1) { id } }`, 2},
+		{"typename is not counted", `{ user(id: 1) { friends { __typename } } }`, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, errs := gqlparser.LoadQuery(schema, tt.query)
+			if errs != nil {
+				t.Fatalf("invalid query: %v", errs)
+			}
+			rc := &graphql.OperationContext{Operation: doc.Operations[0]}
+
+			if err := (DepthLimit{Max: tt.depth}).MutateOperationContext(context.Background(), rc); err != nil {
+				t.Errorf("depth %d rejected at its own limit: %v", tt.depth, err)
+			}
+			err := (DepthLimit{Max: tt.depth - 1}).MutateOperationContext(context.Background(), rc)
+			if err == nil {
+				t.Fatalf("depth %d allowed with a limit of %d", tt.depth, tt.depth-1)
+			}
+			if code := err.Extensions["code"]; code != errDepthLimitCode {
+				t.Errorf("error code = %v, want %s", code, errDepthLimitCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package graph
+
+import (
+	"context"
+	"graphql-gateway/cache"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// Cache policies per type. The User Service receives the caller's token, so
+// user responses are cached per caller; products are the same for everyone.
+var (
+	userCachePolicy    = cache.PolicyFromEnv("USER", cache.PerUser, 5*time.Minute, time.Minute)
+	productCachePolicy = cache.PolicyFromEnv("PRODUCT", cache.Public, 5*time.Minute, time.Minute)
+)
+
+// cachePolicies declares how each field is cached; fields missing here are
+// never cached
+var cachePolicies = map[string]cache.Policy{
+	"Query.users":    userCachePolicy,
+	"Query.user":     userCachePolicy,
+	"Query.products": productCachePolicy,
+	"Query.product":  productCachePolicy,
+}
+
+// fetchCached caches the response of the field being resolved under key with
+// the field's policy
+func fetchCached[T any](ctx context.Context, key string, fetch func(context.Context) (T, error)) (T, error) {
+	policy := cache.Policy{Scope: cache.NoCache}
+	if fieldContext := graphql.GetFieldContext(ctx); fieldContext != nil {
+		if fieldPolicy, ok := cachePolicies[fieldContext.Object+"."+fieldContext.Field.Name]; ok {
+			policy = fieldPolicy
+		}
+	}
+	return cache.Fetch(ctx, key, policy, fetch)
+}
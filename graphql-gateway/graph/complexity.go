@@ -0,0 +1,53 @@
+package graph
+
+import "graphql-gateway/graph/model"
+
+// Page sizes the services apply, used to estimate list fields
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+	// The orders list fields return up to one full page
+	maxListOrders = maxPageSize
+)
+
+// NewComplexityRoot weighs list fields by how many entries they can return,
+// so the complexity limit accounts for queries that fan out over pages of
+// orders and their relationships
+func NewComplexityRoot() ComplexityRoot {
+	var complexity ComplexityRoot
+
+	complexity.Query.Users = func(childComplexity int) int {
+		return defaultPageSize * childComplexity
+	}
+	complexity.Query.Products = func(childComplexity int) int {
+		return defaultPageSize * childComplexity
+	}
+	complexity.Query.Orders = func(childComplexity int, status []string, from *string, to *string) int {
+		return maxListOrders * childComplexity
+	}
+	complexity.Query.OrdersConnection = func(childComplexity int, status []string, from *string, to *string, limit *int, cursor *string) int {
+		return pageSize(limit) * childComplexity
+	}
+	complexity.Query.AdminOrders = func(childComplexity int, filter *model.AdminOrderFilter, sort *string, page *int, size *int) int {
+		return pageSize(size) * childComplexity
+	}
+	complexity.User.Orders = func(childComplexity int) int {
+		return maxListOrders * childComplexity
+	}
+	complexity.User.OrdersConnection = func(childComplexity int, limit *int, cursor *string) int {
+		return pageSize(limit) * childComplexity
+	}
+
+	return complexity
+}
+
+// Helper function to get the page size the services will use for a limit
+func pageSize(limit *int) int {
+	if limit == nil || *limit < 1 {
+		return defaultPageSize
+	}
+	if *limit > maxPageSize {
+		return maxPageSize
+	}
+	return *limit
+}
@@ -0,0 +1,30 @@
+package graph
+
+import (
+	"context"
+	"graphql-gateway/auth"
+	"graphql-gateway/graph/model"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// Auth implements @auth: the field needs a signed-in caller
+func Auth(ctx context.Context, obj interface{}, next graphql.Resolver) (interface{}, error) {
+	if auth.PrincipalFrom(ctx) == nil {
+		return nil, auth.Unauthenticated(ctx)
+	}
+	return next(ctx)
+}
+
+// HasRole implements @hasRole: the field needs a signed-in caller with the
+// role
+func HasRole(ctx context.Context, obj interface{}, next graphql.Resolver, role model.Role) (interface{}, error) {
+	principal := auth.PrincipalFrom(ctx)
+	if principal == nil {
+		return nil, auth.Unauthenticated(ctx)
+	}
+	if !principal.HasRole(string(role)) {
+		return nil, auth.ErrForbidden
+	}
+	return next(ctx)
+}
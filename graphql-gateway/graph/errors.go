@@ -0,0 +1,66 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"graphql-gateway/auth"
+	"graphql-gateway/clients"
+	"graphql-gateway/events"
+	"net/http"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// ErrorPresenter adds a code extension to errors from the services, so
+// clients can tell a missing entity from an outage without parsing messages.
+// Errors that already carry a code, like those of the query limits, are kept.
+func ErrorPresenter(ctx context.Context, err error) *gqlerror.Error {
+	gqlErr := graphql.DefaultErrorPresenter(ctx, err)
+	if _, ok := gqlErr.Extensions["code"]; ok {
+		return gqlErr
+	}
+
+	var serviceErr *clients.Error
+	switch {
+	case errors.Is(err, auth.ErrUnauthenticated) || errors.Is(err, auth.ErrInvalidToken):
+		setCode(gqlErr, "UNAUTHENTICATED")
+	case errors.Is(err, auth.ErrForbidden):
+		setCode(gqlErr, "FORBIDDEN")
+	case errors.Is(err, events.ErrUnavailable):
+		setCode(gqlErr, "UPSTREAM_UNAVAILABLE")
+	case errors.As(err, &serviceErr):
+		setCode(gqlErr, serviceErrorCode(serviceErr.StatusCode))
+		gqlErr.Extensions["service"] = serviceErr.Service
+		if serviceErr.StatusCode != 0 {
+			gqlErr.Extensions["status"] = serviceErr.StatusCode
+		}
+	}
+	return gqlErr
+}
+
+// serviceErrorCode maps the status of a failed service call to an error code
+func serviceErrorCode(status int) string {
+	switch {
+	case status == http.StatusBadRequest || status == http.StatusUnprocessableEntity:
+		return "BAD_USER_INPUT"
+	case status == http.StatusUnauthorized:
+		return "UNAUTHENTICATED"
+	case status == http.StatusForbidden:
+		return "FORBIDDEN"
+	case status == http.StatusNotFound || status == http.StatusGone:
+		return "NOT_FOUND"
+	case status == http.StatusConflict || status == http.StatusPreconditionFailed:
+		return "CONFLICT"
+	case status == 0 || status >= http.StatusInternalServerError:
+		return "UPSTREAM_UNAVAILABLE"
+	}
+	return "UPSTREAM_ERROR"
+}
+
+func setCode(gqlErr *gqlerror.Error, code string) {
+	if gqlErr.Extensions == nil {
+		gqlErr.Extensions = map[string]interface{}{}
+	}
+	gqlErr.Extensions["code"] = code
+}
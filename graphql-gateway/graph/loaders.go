@@ -0,0 +1,131 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"graphql-gateway/clients"
+	"graphql-gateway/graph/model"
+	"net/http"
+	"strconv"
+
+	"github.com/graph-gophers/dataloader"
+)
+
+// Loaders batch the user and product lookups of one request, so resolving
+// Order.user or OrderItem.product for a whole list takes a single call to
+// each service
+type Loaders struct {
+	UserByID    *dataloader.Loader
+	ProductByID *dataloader.Loader
+}
+
+func NewLoaders() *Loaders {
+	return &Loaders{
+		UserByID:    dataloader.NewBatchedLoader(batchUsers),
+		ProductByID: dataloader.NewBatchedLoader(batchProducts),
+	}
+}
+
+type loadersContextKey struct{}
+
+// LoaderMiddleware gives every request its own loaders, so loaded entities
+// are only cached for the duration of the request
+func LoaderMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), loadersContextKey{}, NewLoaders())
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Helper function to get the request's loaders from context
+func loadersFor(ctx context.Context) *Loaders {
+	loaders, ok := ctx.Value(loadersContextKey{}).(*Loaders)
+	if !ok {
+		// Without the middleware every call gets its own, unbatched loaders
+		return NewLoaders()
+	}
+	return loaders
+}
+
+func batchUsers(ctx context.Context, keys dataloader.Keys) []*dataloader.Result {
+	users, err := fetchUsersByID(ctx, keys.Keys())
+
+	results := make([]*dataloader.Result, len(keys))
+	for i, key := range keys {
+		if err != nil {
+			results[i] = &dataloader.Result{Error: err}
+			continue
+		}
+		results[i] = &dataloader.Result{Data: users[key.String()]}
+	}
+	return results
+}
+
+func batchProducts(ctx context.Context, keys dataloader.Keys) []*dataloader.Result {
+	products, err := fetchProductsByID(ctx, keys.Keys())
+
+	results := make([]*dataloader.Result, len(keys))
+	for i, key := range keys {
+		if err != nil {
+			results[i] = &dataloader.Result{Error: err}
+			continue
+		}
+		results[i] = &dataloader.Result{Data: products[key.String()]}
+	}
+	return results
+}
+
+// Helper function to fetch users with the User Service batch endpoint,
+// keyed by ID
+func fetchUsersByID(ctx context.Context, keys []string) (map[string]*model.User, error) {
+	ids, err := parseKeys(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	usersData, err := clients.Users.List(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve users: %w", err)
+	}
+
+	users := map[string]*model.User{}
+	for i := range usersData {
+		user := convertUser(&usersData[i])
+		users[user.ID] = user
+	}
+	return users, nil
+}
+
+// Helper function to fetch products, archived ones included, with the
+// Product Service batch endpoint, keyed by ID
+func fetchProductsByID(ctx context.Context, keys []string) (map[string]*model.Product, error) {
+	ids, err := parseKeys(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	productsData, err := clients.Products.List(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve products: %w", err)
+	}
+
+	products := map[string]*model.Product{}
+	for i := range productsData {
+		product := convertProduct(&productsData[i])
+		products[product.ID] = product
+	}
+	return products, nil
+}
+
+// Helper function to convert loader keys to IDs
+func parseKeys(keys []string) ([]int, error) {
+	ids := make([]int, len(keys))
+	for i, key := range keys {
+		id, err := strconv.Atoi(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ID %q", key)
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
@@ -2,28 +2,120 @@
 
 package model
 
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+type AddToCartInput struct {
+	CartID    *string `json:"cart_id,omitempty"`
+	ProductID int     `json:"product_id"`
+	Quantity  int     `json:"quantity"`
+}
+
+type AdminOrderFilter struct {
+	Status     []string `json:"status,omitempty"`
+	UserID     *int     `json:"user_id,omitempty"`
+	ProductID  *int     `json:"product_id,omitempty"`
+	CouponCode *string  `json:"coupon_code,omitempty"`
+	From       *string  `json:"from,omitempty"`
+	To         *string  `json:"to,omitempty"`
+	MinTotal   *float64 `json:"min_total,omitempty"`
+	MaxTotal   *float64 `json:"max_total,omitempty"`
+}
+
+type AdminOrderPage struct {
+	Orders     []*Order `json:"orders"`
+	Page       int      `json:"page"`
+	PageSize   int      `json:"page_size"`
+	TotalCount int      `json:"total_count"`
+}
+
+type AuthPayload struct {
+	Token string `json:"token"`
+	User  *User  `json:"user"`
+}
+
+type CancelOrderResponse struct {
+	Message        string  `json:"message"`
+	OrderID        string  `json:"order_id"`
+	RefundedAmount float64 `json:"refunded_amount"`
+}
+
+type Cart struct {
+	CartID       *string     `json:"cart_id,omitempty"`
+	Items        []*CartItem `json:"items"`
+	ItemCount    int         `json:"item_count"`
+	Subtotal     float64     `json:"subtotal"`
+	Checkoutable bool        `json:"checkoutable"`
+}
+
+type CartItem struct {
+	ProductID         int     `json:"product_id"`
+	Name              string  `json:"name"`
+	Quantity          int     `json:"quantity"`
+	UnitPrice         float64 `json:"unit_price"`
+	LineTotal         float64 `json:"line_total"`
+	Available         bool    `json:"available"`
+	AvailableQuantity int     `json:"available_quantity"`
+	Issue             *string `json:"issue,omitempty"`
+}
+
+type InventoryUpdate struct {
+	ProductID string `json:"product_id"`
+	Inventory int    `json:"inventory"`
+}
+
+type LoginInput struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
 type Mutation struct {
 }
 
 type Order struct {
-	ID        string       `json:"id"`
-	UserID    int          `json:"user_id"`
-	Status    string       `json:"status"`
-	Total     float64      `json:"total"`
-	CreatedAt string       `json:"created_at"`
-	Items     []*OrderItem `json:"items"`
+	ID              string           `json:"id"`
+	UserID          int              `json:"user_id"`
+	Status          string           `json:"status"`
+	Subtotal        float64          `json:"subtotal"`
+	DiscountTotal   float64          `json:"discount_total"`
+	TaxTotal        float64          `json:"tax_total"`
+	ShippingTotal   float64          `json:"shipping_total"`
+	Total           float64          `json:"total"`
+	CouponCode      *string          `json:"coupon_code,omitempty"`
+	FreeShipping    bool             `json:"free_shipping"`
+	CreatedAt       string           `json:"created_at"`
+	CancelledAt     *string          `json:"cancelled_at,omitempty"`
+	CancelReason    *string          `json:"cancel_reason,omitempty"`
+	ShippingAddress *ShippingAddress `json:"shipping_address,omitempty"`
+	Items           []*OrderItem     `json:"items"`
+	Shipments       []*Shipment      `json:"shipments"`
+	User            *User            `json:"user,omitempty"`
+}
+
+type OrderConnection struct {
+	Orders     []*Order `json:"orders"`
+	NextCursor *string  `json:"next_cursor,omitempty"`
+	HasMore    bool     `json:"has_more"`
 }
 
 type OrderInput struct {
-	Items []*OrderItemInput `json:"items"`
+	Items             []*OrderItemInput `json:"items"`
+	ShippingAddressID *int              `json:"shipping_address_id,omitempty"`
+	CouponCode        *string           `json:"coupon_code,omitempty"`
 }
 
 type OrderItem struct {
-	ID        string  `json:"id"`
-	OrderID   int     `json:"order_id"`
-	ProductID int     `json:"product_id"`
-	Quantity  int     `json:"quantity"`
-	Price     float64 `json:"price"`
+	ID        string   `json:"id"`
+	OrderID   int      `json:"order_id"`
+	ProductID int      `json:"product_id"`
+	Quantity  int      `json:"quantity"`
+	Price     float64  `json:"price"`
+	Discount  float64  `json:"discount"`
+	Tax       float64  `json:"tax"`
+	Product   *Product `json:"product,omitempty"`
 }
 
 type OrderItemInput struct {
@@ -36,22 +128,67 @@ type OrderResponse struct {
 	OrderID string `json:"order_id"`
 }
 
+type OrderStatusUpdate struct {
+	OrderID        string  `json:"order_id"`
+	Status         string  `json:"status"`
+	Event          string  `json:"event"`
+	Carrier        *string `json:"carrier,omitempty"`
+	TrackingNumber *string `json:"tracking_number,omitempty"`
+}
+
+type PriceQuote struct {
+	Items           []*QuoteItem     `json:"items"`
+	Subtotal        float64          `json:"subtotal"`
+	DiscountTotal   float64          `json:"discount_total"`
+	TaxTotal        float64          `json:"tax_total"`
+	ShippingTotal   float64          `json:"shipping_total"`
+	Total           float64          `json:"total"`
+	CouponCode      *string          `json:"coupon_code,omitempty"`
+	FreeShipping    bool             `json:"free_shipping"`
+	WeightGrams     int              `json:"weight_grams"`
+	ShippingAddress *ShippingAddress `json:"shipping_address,omitempty"`
+}
+
 type Product struct {
-	ID          string  `json:"id"`
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
-	Inventory   int     `json:"inventory"`
-	CreatedAt   string  `json:"created_at"`
+	ID          string          `json:"id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Price       float64         `json:"price"`
+	Category    string          `json:"category"`
+	TaxClass    string          `json:"tax_class"`
+	WeightGrams int             `json:"weight_grams"`
+	Inventory   int             `json:"inventory"`
+	Version     int             `json:"version"`
+	CreatedAt   string          `json:"created_at"`
+	ArchivedAt  *string         `json:"archived_at,omitempty"`
+	Images      []*ProductImage `json:"images"`
+}
+
+type ProductImage struct {
+	ID           string `json:"id"`
+	Position     int    `json:"position"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	ContentType  string `json:"content_type"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
 }
 
 type ProductInput struct {
 	Name        string  `json:"name"`
 	Description string  `json:"description"`
 	Price       float64 `json:"price"`
+	Category    *string `json:"category,omitempty"`
+	TaxClass    *string `json:"tax_class,omitempty"`
+	WeightGrams *int    `json:"weight_grams,omitempty"`
 	Inventory   int     `json:"inventory"`
 }
 
+type ProductPayload struct {
+	Message string   `json:"message"`
+	Product *Product `json:"product"`
+}
+
 type ProductResponse struct {
 	Message string `json:"message"`
 }
@@ -59,6 +196,15 @@ type ProductResponse struct {
 type Query struct {
 }
 
+type QuoteItem struct {
+	ProductID int     `json:"product_id"`
+	Quantity  int     `json:"quantity"`
+	UnitPrice float64 `json:"unit_price"`
+	Discount  float64 `json:"discount"`
+	Tax       float64 `json:"tax"`
+	LineTotal float64 `json:"line_total"`
+}
+
 type RegisterInput struct {
 	Username string `json:"username"`
 	Email    string `json:"email"`
@@ -69,9 +215,103 @@ type RegisterUserResponse struct {
 	Message string `json:"message"`
 }
 
+type Shipment struct {
+	ID             string          `json:"id"`
+	Carrier        string          `json:"carrier"`
+	TrackingNumber string          `json:"tracking_number"`
+	Status         string          `json:"status"`
+	ShippedAt      string          `json:"shipped_at"`
+	DeliveredAt    *string         `json:"delivered_at,omitempty"`
+	Items          []*ShipmentItem `json:"items"`
+}
+
+type ShipmentItem struct {
+	OrderItemID int `json:"order_item_id"`
+	ProductID   int `json:"product_id"`
+	Quantity    int `json:"quantity"`
+}
+
+type ShippingAddress struct {
+	AddressID  int    `json:"address_id"`
+	Label      string `json:"label"`
+	FullName   string `json:"full_name"`
+	Line1      string `json:"line1"`
+	Line2      string `json:"line2"`
+	City       string `json:"city"`
+	Region     string `json:"region"`
+	PostalCode string `json:"postal_code"`
+	Country    string `json:"country"`
+	Phone      string `json:"phone"`
+}
+
+type Subscription struct {
+}
+
+type UpdateProductInput struct {
+	Name        *string  `json:"name,omitempty"`
+	Description *string  `json:"description,omitempty"`
+	Price       *float64 `json:"price,omitempty"`
+	Category    *string  `json:"category,omitempty"`
+	TaxClass    *string  `json:"tax_class,omitempty"`
+	WeightGrams *int     `json:"weight_grams,omitempty"`
+	Version     *int     `json:"version,omitempty"`
+}
+
+type UpdateProfileInput struct {
+	Email string `json:"email"`
+}
+
 type User struct {
-	ID        string `json:"id"`
-	Username  string `json:"username"`
-	Email     string `json:"email"`
-	CreatedAt string `json:"created_at"`
+	ID               string           `json:"id"`
+	Username         string           `json:"username"`
+	Email            string           `json:"email"`
+	CreatedAt        string           `json:"created_at"`
+	Orders           []*Order         `json:"orders"`
+	OrdersConnection *OrderConnection `json:"ordersConnection"`
+}
+
+type UserPayload struct {
+	Message string `json:"message"`
+	User    *User  `json:"user"`
+}
+
+type Role string
+
+const (
+	RoleAdmin Role = "ADMIN"
+	RoleUser  Role = "USER"
+)
+
+var AllRole = []Role{
+	RoleAdmin,
+	RoleUser,
+}
+
+func (e Role) IsValid() bool {
+	switch e {
+	case RoleAdmin, RoleUser:
+		return true
+	}
+	return false
+}
+
+func (e Role) String() string {
+	return string(e)
+}
+
+func (e *Role) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = Role(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid Role", str)
+	}
+	return nil
+}
+
+func (e Role) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
 }
@@ -1,718 +1,621 @@
 package graph
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"graphql-gateway/auth"
 	"graphql-gateway/cache"
+	"graphql-gateway/clients"
+	"graphql-gateway/events"
 	"graphql-gateway/graph/model"
-	"io"
 	"log"
 	"net/http"
-	"os"
-	"time"
+	"net/url"
+	"strconv"
 
-	"github.com/go-redis/redis"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/graph-gophers/dataloader"
+	"github.com/vektah/gqlparser/v2/gqlerror"
 )
 
 type Resolver struct{}
 
 // Query Resolver Implementation
 func (r *Resolver) Users(ctx context.Context) ([]*model.User, error) {
-	// Define the cache key
-	cacheKey := "users"
-
-	// Try to get data from Redis cache
-	cachedData, err := cache.RedisClient.Get(cacheKey).Result()
-	if err == nil {
-		// Cache hit: unmarshal the cached data
-		var users []*model.User
-		if err := json.Unmarshal([]byte(cachedData), &users); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal cached users: %v", err)
+	return fetchCached(ctx, "users", func(ctx context.Context) ([]*model.User, error) {
+		users, err := clients.Users.List(ctx, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve users: %w", err)
 		}
-		fmt.Println("Cache hit: returning users from Redis")
-		return users, nil
-	} else if err != redis.Nil {
-		// Redis error (other than key not found)
-		return nil, fmt.Errorf("failed to get users from Redis: %v", err)
-	}
-
-	// Cache miss: fetch from User Service
-	fmt.Println("Cache miss: fetching users from User Service")
-	url := fmt.Sprintf("%s/users", getUserServiceURL())
 
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("Authorization", getAuthHeader(ctx))
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to retrieve users: %s", resp.Status)
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
+		usersPtr := []*model.User{}
+		for i := range users {
+			usersPtr = append(usersPtr, convertUser(&users[i]))
+		}
+		return usersPtr, nil
+	})
+}
 
-	var result map[string][]map[string]interface{}
-	err = json.Unmarshal(body, &result)
-	if err != nil {
-		return nil, err
+// UserByID backs the user query; User() is taken by the User type resolver.
+// Only admins can read other users.
+func (r *Resolver) UserByID(ctx context.Context, id string) (*model.User, error) {
+	principal := auth.PrincipalFrom(ctx)
+	if principal == nil {
+		return nil, auth.Unauthenticated(ctx)
 	}
-
-	users, ok := result["users"]
-	if !ok {
-		return nil, fmt.Errorf("unexpected response format")
+	if strconv.Itoa(principal.UserID) != id && !principal.HasRole(auth.RoleAdmin) {
+		return nil, auth.ErrForbidden
 	}
-
-	var usersPtr []*model.User
-	for _, user := range users {
-		idFloat, ok := user["id"].(float64)
-		if !ok {
-			return nil, fmt.Errorf("unexpected type for id")
+	return fetchCached(ctx, fmt.Sprintf("user:%s", id), func(ctx context.Context) (*model.User, error) {
+		user, err := clients.Users.Get(ctx, id)
+		if clients.IsNotFound(err) {
+			return nil, nil
 		}
-
-		userModel := &model.User{
-			ID:        fmt.Sprintf("%d", int(idFloat)),
-			Username:  user["username"].(string),
-			Email:     user["email"].(string),
-			CreatedAt: user["created_at"].(string),
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve user: %w", err)
 		}
-		usersPtr = append(usersPtr, userModel)
-	}
-
-	// Store data in Redis cache for 5 minutes
-	usersJSON, err := json.Marshal(usersPtr)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal users for caching: %v", err)
-	}
-	err = cache.RedisClient.Set(cacheKey, usersJSON, 5*time.Minute).Err()
-	if err != nil {
-		return nil, fmt.Errorf("failed to set users in Redis cache: %v", err)
-	}
-
-	return usersPtr, nil
+		return convertUser(user), nil
+	})
 }
 
-func (r *Resolver) User(ctx context.Context, id string) (*model.User, error) {
-	// Define the cache key for the specific user
-	cacheKey := fmt.Sprintf("user:%s", id)
-
-	// Try to get data from Redis cache
-	cachedData, err := cache.RedisClient.Get(cacheKey).Result()
-	if err == nil {
-		// Cache hit: unmarshal the cached data
-		var user model.User
-		if err := json.Unmarshal([]byte(cachedData), &user); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal cached user: %v", err)
-		}
-		fmt.Println("Cache hit: returning user from Redis")
-		return &user, nil
-	} else if err != redis.Nil {
-		// Redis error (other than key not found)
-		return nil, fmt.Errorf("failed to get user from Redis: %v", err)
-	}
-
-	// Cache miss: fetch from User Service
-	fmt.Println("Cache miss: fetching user from User Service")
-	url := fmt.Sprintf("%s/users/%s", getUserServiceURL(), id)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("Authorization", getAuthHeader(ctx))
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
+func (r *Resolver) RegisterUser(ctx context.Context, input model.RegisterInput) (*model.RegisterUserResponse, error) {
+	message, err := clients.Users.Register(ctx, input)
 	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == http.StatusNotFound {
-		return nil, nil
+		return nil, fmt.Errorf("failed to register user: %w", err)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to retrieve user: %s", resp.Status)
-	}
+	// The new user belongs in the cached list; the user_registered event
+	// clears it for the other gateway instances
+	cache.Invalidate("users")
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
+	return &model.RegisterUserResponse{Message: message}, nil
+}
 
-	// Unmarshal the JSON response into a map to extract the user data
-	var result map[string]map[string]interface{}
-	err = json.Unmarshal(body, &result)
+func (r *Resolver) Login(ctx context.Context, input model.LoginInput) (*model.AuthPayload, error) {
+	token, user, err := clients.Users.Login(ctx, input)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to log in: %w", err)
 	}
-
-	userData, ok := result["user"]
-	if !ok {
+	if user == nil {
 		return nil, fmt.Errorf("unexpected response format")
 	}
 
-	idFloat, ok := userData["id"].(float64)
-	if !ok {
-		return nil, fmt.Errorf("unexpected type for id")
-	}
-
-	user := &model.User{
-		ID:        fmt.Sprintf("%d", int(idFloat)),
-		Username:  userData["username"].(string),
-		Email:     userData["email"].(string),
-		CreatedAt: userData["created_at"].(string),
-	}
-
-	// Store data in Redis cache for 5 minutes
-	userJSON, err := json.Marshal(user)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal user for caching: %v", err)
-	}
-	err = cache.RedisClient.Set(cacheKey, userJSON, 5*time.Minute).Err()
-	if err != nil {
-		return nil, fmt.Errorf("failed to set user in Redis cache: %v", err)
-	}
-
-	return user, nil
+	return &model.AuthPayload{Token: token, User: convertUser(user)}, nil
 }
 
-func (r *Resolver) RegisterUser(ctx context.Context, input model.RegisterInput) (*model.RegisterUserResponse, error) {
-	url := fmt.Sprintf("%s/register", getUserServiceURL())
-
-	userData, err := json.Marshal(input)
+func (r *Resolver) UpdateProfile(ctx context.Context, input model.UpdateProfileInput) (*model.UserPayload, error) {
+	message, user, err := clients.Users.UpdateProfile(ctx, input)
 	if err != nil {
-		return nil, err
-	}
-
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(userData))
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to register user: %s", resp.Status)
-	}
-
-	var apiResponse map[string]string
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	err = json.Unmarshal(body, &apiResponse)
-	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to update profile: %w", err)
 	}
-
-	message, exists := apiResponse["message"]
-	if !exists {
+	if user == nil {
 		return nil, fmt.Errorf("unexpected response format")
 	}
 
-	return &model.RegisterUserResponse{Message: message}, nil
+	cache.Invalidate(cache.UserKeys(user.ID)...)
+
+	return &model.UserPayload{Message: message, User: convertUser(user)}, nil
 }
 
-// Helper function to get User Service URL
-func getUserServiceURL() string {
-	url := os.Getenv("USER_SERVICE_URL")
-	if url == "" {
-		url = "http://user-service:8081"
+// Helper function to convert a user from a User Service response
+func convertUser(user *clients.User) *model.User {
+	return &model.User{
+		ID:        strconv.Itoa(user.ID),
+		Username:  user.Username,
+		Email:     user.Email,
+		CreatedAt: user.CreatedAt,
 	}
-	return url
 }
 
 func (r *Resolver) Products(ctx context.Context) ([]*model.Product, error) {
-	// Define the cache key
-	cacheKey := "products"
-
-	// Try to get data from Redis cache
-	cachedData, err := cache.RedisClient.Get(cacheKey).Result()
-	if err == nil {
-		// Cache hit: unmarshal the cached data
-		var products []*model.Product
-		if err := json.Unmarshal([]byte(cachedData), &products); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal cached products: %v", err)
+	return fetchCached(ctx, "products", func(ctx context.Context) ([]*model.Product, error) {
+		productsData, err := clients.Products.List(ctx, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve products: %w", err)
 		}
-		fmt.Println("Cache hit: returning products from Redis")
-		return products, nil
-	} else if err != redis.Nil {
-		// Redis error (other than key not found)
-		return nil, fmt.Errorf("failed to get products from Redis: %v", err)
-	}
 
-	// Cache miss: fetch from Product Service
-	fmt.Println("Cache miss: fetching products from Product Service")
-	url := fmt.Sprintf("%s/products", getProductServiceURL())
+		products := []*model.Product{}
+		for i := range productsData {
+			products = append(products, convertProduct(&productsData[i]))
+		}
+		return products, nil
+	})
+}
 
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
+func (r *Resolver) Product(ctx context.Context, id string) (*model.Product, error) {
+	return fetchCached(ctx, fmt.Sprintf("product:%s", id), func(ctx context.Context) (*model.Product, error) {
+		product, err := clients.Products.Get(ctx, id)
+		if clients.IsNotFound(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve product: %w", err)
+		}
+		return convertProduct(product), nil
+	})
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+func (r *Resolver) CreateProduct(ctx context.Context, input model.ProductInput) (*model.ProductResponse, error) {
+	message, err := clients.Products.Create(ctx, input)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to create product: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to retrieve products: %s", resp.Status)
-	}
+	// The new product belongs in the cached list; the product_created event
+	// clears it for the other gateway instances
+	cache.Invalidate("products")
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
+	return &model.ProductResponse{Message: message}, nil
+}
 
-	// Parse the response into a generic map to manipulate data types
-	var result map[string][]map[string]interface{}
-	err = json.Unmarshal(body, &result)
+func (r *Resolver) UpdateProduct(ctx context.Context, id string, input model.UpdateProductInput) (*model.ProductPayload, error) {
+	// PATCH only touches the fields that were given
+	message, product, err := clients.Products.Update(ctx, id, input)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to update product: %w", err)
 	}
 
-	productsData, ok := result["products"]
-	if !ok {
-		return nil, fmt.Errorf("unexpected response format")
-	}
+	cache.Invalidate(cache.ProductKeys(product.ID)...)
 
-	var products []*model.Product
-	for _, product := range productsData {
-		idFloat, ok := product["id"].(float64)
-		if !ok {
-			return nil, fmt.Errorf("unexpected type for product id")
-		}
-		productModel := &model.Product{
-			ID:          fmt.Sprintf("%d", int(idFloat)),
-			Name:        product["name"].(string),
-			Description: product["description"].(string),
-			Price:       product["price"].(float64),
-			Inventory:   int(product["inventory"].(float64)),
-			CreatedAt:   product["created_at"].(string),
-		}
-		products = append(products, productModel)
-	}
+	return &model.ProductPayload{Message: message, Product: convertProduct(product)}, nil
+}
 
-	// Store data in Redis cache for 5 minutes
-	productsJSON, err := json.Marshal(products)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal products for caching: %v", err)
-	}
-	err = cache.RedisClient.Set(cacheKey, productsJSON, 5*time.Minute).Err()
+func (r *Resolver) DeleteProduct(ctx context.Context, id string) (*model.ProductPayload, error) {
+	message, product, err := clients.Products.Delete(ctx, id)
 	if err != nil {
-		return nil, fmt.Errorf("failed to set products in Redis cache: %v", err)
+		return nil, fmt.Errorf("failed to delete product: %w", err)
 	}
 
-	return products, nil
+	cache.Invalidate(cache.ProductKeys(product.ID)...)
+
+	return &model.ProductPayload{Message: message, Product: convertProduct(product)}, nil
 }
 
-func (r *Resolver) Product(ctx context.Context, id string) (*model.Product, error) {
-	// Define the cache key for the specific product
-	cacheKey := fmt.Sprintf("product:%s", id)
-
-	// Try to get data from Redis cache
-	cachedData, err := cache.RedisClient.Get(cacheKey).Result()
-	if err == nil {
-		// Cache hit: unmarshal the cached data
-		var product *model.Product
-		if err := json.Unmarshal([]byte(cachedData), &product); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal cached product: %v", err)
-		}
-		fmt.Println("Cache hit: returning product from Redis")
-		return product, nil
-	} else if err != redis.Nil {
-		// Redis error (other than key not found)
-		return nil, fmt.Errorf("failed to get product from Redis: %v", err)
+// Helper function to convert a product from a Product Service response
+func convertProduct(product *clients.Product) *model.Product {
+	productModel := &model.Product{
+		ID:          strconv.Itoa(product.ID),
+		Name:        product.Name,
+		Description: product.Description,
+		Price:       product.Price,
+		Category:    product.Category,
+		TaxClass:    product.TaxClass,
+		WeightGrams: product.WeightGrams,
+		Inventory:   product.Inventory,
+		Version:     product.Version,
+		CreatedAt:   product.CreatedAt,
+		ArchivedAt:  product.ArchivedAt,
+		Images:      []*model.ProductImage{},
+	}
+	for _, image := range product.Images {
+		productModel.Images = append(productModel.Images, &model.ProductImage{
+			ID:           strconv.Itoa(image.ID),
+			Position:     image.Position,
+			URL:          image.URL,
+			ThumbnailURL: image.ThumbnailURL,
+			ContentType:  image.ContentType,
+			Width:        image.Width,
+			Height:       image.Height,
+		})
 	}
+	return productModel
+}
 
-	// Cache miss: fetch from Product Service
-	fmt.Println("Cache miss: fetching product from Product Service")
-	url := fmt.Sprintf("%s/products/%s", getProductServiceURL(), id)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
+func (r *Resolver) Orders(ctx context.Context, status []string, from *string, to *string) ([]*model.Order, error) {
+	return fetchOrderList(ctx, orderFilterParams(status, from, to))
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
+func (r *Resolver) OrdersConnection(ctx context.Context, status []string, from *string, to *string, limit *int, cursor *string) (*model.OrderConnection, error) {
+	return fetchOrders(ctx, orderFilterParams(status, from, to), limit, cursor)
+}
 
-	if resp.StatusCode == http.StatusNotFound {
-		return nil, nil
+// Helper function to build the Order Service's filter parameters
+func orderFilterParams(status []string, from *string, to *string) url.Values {
+	params := url.Values{}
+	for _, s := range status {
+		params.Add("status", s)
 	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to retrieve product: %s", resp.Status)
+	if from != nil {
+		params.Set("from", *from)
 	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+	if to != nil {
+		params.Set("to", *to)
 	}
+	return params
+}
 
-	// Parse the response into a generic map to manipulate data types
-	var result map[string]map[string]interface{}
-	err = json.Unmarshal(body, &result)
+// Helper function to fetch the orders matching the filters for the list
+// fields that predate pagination. They return at most maxListOrders orders,
+// in one call to the Order Service; if more match, a RESULT_TRUNCATED error
+// points the client to ordersConnection.
+func fetchOrderList(ctx context.Context, params url.Values) ([]*model.Order, error) {
+	limit := maxListOrders
+	page, err := fetchOrders(ctx, params, &limit, nil)
 	if err != nil {
 		return nil, err
 	}
-
-	productData, ok := result["product"]
-	if !ok {
-		return nil, fmt.Errorf("unexpected response format")
+	if page.HasMore {
+		graphql.AddError(ctx, &gqlerror.Error{
+			Message:    fmt.Sprintf("only the first %d orders are returned; use ordersConnection to fetch the rest", maxListOrders),
+			Path:       graphql.GetPath(ctx),
+			Extensions: map[string]interface{}{"code": "RESULT_TRUNCATED"},
+		})
 	}
+	return page.Orders, nil
+}
 
-	idFloat, ok := productData["id"].(float64)
-	if !ok {
-		return nil, fmt.Errorf("unexpected type for product id")
+// Helper function to fetch a page of orders from the Order Service with the
+// given filters
+func fetchOrders(ctx context.Context, params url.Values, limit *int, cursor *string) (*model.OrderConnection, error) {
+	if limit != nil {
+		params.Set("limit", strconv.Itoa(*limit))
 	}
-
-	product := &model.Product{
-		ID:          fmt.Sprintf("%d", int(idFloat)),
-		Name:        productData["name"].(string),
-		Description: productData["description"].(string),
-		Price:       productData["price"].(float64),
-		Inventory:   int(productData["inventory"].(float64)),
-		CreatedAt:   productData["created_at"].(string),
+	if cursor != nil {
+		params.Set("cursor", *cursor)
 	}
 
-	// Store data in Redis cache for 5 minutes
-	productJSON, err := json.Marshal(product)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal product for caching: %v", err)
-	}
-	err = cache.RedisClient.Set(cacheKey, productJSON, 5*time.Minute).Err()
+	page, err := clients.Orders.List(ctx, params)
 	if err != nil {
-		return nil, fmt.Errorf("failed to set product in Redis cache: %v", err)
+		return nil, fmt.Errorf("failed to retrieve orders: %w", err)
 	}
 
-	return product, nil
+	return &model.OrderConnection{
+		Orders:     convertOrders(page.Orders),
+		NextCursor: page.NextCursor,
+		HasMore:    page.HasMore,
+	}, nil
 }
 
-func (r *Resolver) CreateProduct(ctx context.Context, input model.ProductInput) (*model.ProductResponse, error) {
-	url := fmt.Sprintf("%s/products", getProductServiceURL())
-
-	productData, err := json.Marshal(input)
-	if err != nil {
-		return nil, err
+// OrderByID backs the order query; Order() is taken by the Order type resolver
+func (r *Resolver) OrderByID(ctx context.Context, id string) (*model.Order, error) {
+	order, err := clients.Orders.Get(ctx, id)
+	if clients.IsNotFound(err) {
+		return nil, nil
 	}
-
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(productData))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to retrieve order: %w", err)
 	}
+	return convertOrder(order), nil
+}
 
-	req.Header.Set("Content-Type", "application/json")
-
-	// Get JWT token from context
-	authHeader := getAuthHeader(ctx)
-	if authHeader == "" {
-		return nil, fmt.Errorf("unauthorized: missing Authorization header")
+func (r *Resolver) AdminOrders(ctx context.Context, filter *model.AdminOrderFilter, sort *string, page *int, pageSize *int) (*model.AdminOrderPage, error) {
+	params := url.Values{}
+	if filter != nil {
+		for _, status := range filter.Status {
+			params.Add("status", status)
+		}
+		if filter.UserID != nil {
+			params.Set("user_id", strconv.Itoa(*filter.UserID))
+		}
+		if filter.ProductID != nil {
+			params.Set("product_id", strconv.Itoa(*filter.ProductID))
+		}
+		if filter.CouponCode != nil {
+			params.Set("coupon_code", *filter.CouponCode)
+		}
+		if filter.From != nil {
+			params.Set("from", *filter.From)
+		}
+		if filter.To != nil {
+			params.Set("to", *filter.To)
+		}
+		if filter.MinTotal != nil {
+			params.Set("min_total", strconv.FormatFloat(*filter.MinTotal, 'f', -1, 64))
+		}
+		if filter.MaxTotal != nil {
+			params.Set("max_total", strconv.FormatFloat(*filter.MaxTotal, 'f', -1, 64))
+		}
 	}
-
-	req.Header.Set("Authorization", authHeader)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
+	if sort != nil {
+		params.Set("sort", *sort)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to create product: %s", resp.Status)
+	if page != nil {
+		params.Set("page", strconv.Itoa(*page))
 	}
-
-	var apiResponse map[string]interface{}
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+	if pageSize != nil {
+		params.Set("page_size", strconv.Itoa(*pageSize))
 	}
 
-	err = json.Unmarshal(body, &apiResponse)
+	result, err := clients.Orders.AdminList(ctx, params)
 	if err != nil {
-		return nil, err
-	}
-
-	// Convert "message" field to string
-	messageInterface, exists := apiResponse["message"]
-	if !exists {
-		return nil, fmt.Errorf("unexpected response format: 'message' key not found")
+		return nil, fmt.Errorf("failed to retrieve orders: %w", err)
 	}
 
-	message, ok := messageInterface.(string)
-	if !ok {
-		return nil, fmt.Errorf("unexpected response format: 'message' value is not a string")
-	}
-
-	return &model.ProductResponse{Message: message}, nil
-}
-
-// Helper function to get Product Service URL
-func getProductServiceURL() string {
-	url := os.Getenv("PRODUCT_SERVICE_URL")
-	if url == "" {
-		url = "http://product-service:8082"
-	}
-	return url
-}
-
-// Helper function to get authorization header from context
-func getAuthHeader(ctx context.Context) string {
-	authHeader, present := ctx.Value("Authorization").(string)
-	if !present {
-		log.Printf("no authorization %v, %v\n", present, authHeader)
-	}
-	return authHeader
+	return &model.AdminOrderPage{
+		Orders:     convertOrders(result.Orders),
+		Page:       result.Page,
+		PageSize:   result.PageSize,
+		TotalCount: result.TotalCount,
+	}, nil
 }
 
-func (r *Resolver) Orders(ctx context.Context) ([]*model.Order, error) {
-	url := fmt.Sprintf("%s/orders", getOrderServiceURL())
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("Authorization", getAuthHeader(ctx))
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
+func (r *Resolver) CancelOrder(ctx context.Context, id string, reason *string) (*model.CancelOrderResponse, error) {
+	result, err := clients.Orders.Cancel(ctx, id, reason)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to cancel order: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to retrieve orders: %s", resp.Status)
-	}
+	return &model.CancelOrderResponse{
+		Message:        result.Message,
+		OrderID:        strconv.Itoa(result.OrderID),
+		RefundedAmount: result.RefundedAmount,
+	}, nil
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	var result map[string][]map[string]interface{}
-	err = json.Unmarshal(body, &result)
-	if err != nil {
-		return nil, err
+// Helper function to convert a list of orders from an Order Service response
+func convertOrders(orders []clients.Order) []*model.Order {
+	orderModels := []*model.Order{}
+	for i := range orders {
+		orderModels = append(orderModels, convertOrder(&orders[i]))
 	}
+	return orderModels
+}
 
-	ordersData, ok := result["orders"]
-	if !ok {
-		return nil, fmt.Errorf("unexpected response format")
+// Helper function to convert an order, with its items and shipments, from an
+// Order Service response
+func convertOrder(order *clients.Order) *model.Order {
+	orderModel := &model.Order{
+		ID:              strconv.Itoa(order.ID),
+		UserID:          order.UserID,
+		Status:          order.Status,
+		Subtotal:        order.Subtotal,
+		DiscountTotal:   order.DiscountTotal,
+		TaxTotal:        order.TaxTotal,
+		ShippingTotal:   order.ShippingTotal,
+		Total:           order.Total,
+		CouponCode:      order.CouponCode,
+		FreeShipping:    order.FreeShipping,
+		CreatedAt:       order.CreatedAt,
+		CancelledAt:     order.CancelledAt,
+		CancelReason:    order.CancelReason,
+		ShippingAddress: order.ShippingAddress,
+		Items:           []*model.OrderItem{},
+		Shipments:       []*model.Shipment{},
+	}
+
+	for _, item := range order.Items {
+		orderModel.Items = append(orderModel.Items, &model.OrderItem{
+			ID:        strconv.Itoa(item.ID),
+			OrderID:   item.OrderID,
+			ProductID: item.ProductID,
+			Quantity:  item.Quantity,
+			Price:     item.Price,
+			Discount:  item.Discount,
+			Tax:       item.Tax,
+		})
 	}
 
-	var orders []*model.Order
-	for _, order := range ordersData {
-		idFloat, ok := order["id"].(float64)
-		if !ok {
-			return nil, fmt.Errorf("unexpected type for order id")
+	for _, shipment := range order.Shipments {
+		shipmentModel := &model.Shipment{
+			ID:             strconv.Itoa(shipment.ID),
+			Carrier:        shipment.Carrier,
+			TrackingNumber: shipment.TrackingNumber,
+			Status:         shipment.Status,
+			ShippedAt:      shipment.ShippedAt,
+			DeliveredAt:    shipment.DeliveredAt,
+			Items:          []*model.ShipmentItem{},
 		}
-
-		orderModel := &model.Order{
-			ID:        fmt.Sprintf("%d", int(idFloat)),
-			UserID:    int(order["user_id"].(float64)),
-			Status:    order["status"].(string),
-			Total:     order["total"].(float64),
-			CreatedAt: order["created_at"].(string),
-		}
-
-		// Parsing items
-		items := order["items"].([]interface{})
-		for _, item := range items {
-			itemMap := item.(map[string]interface{})
-			itemID, _ := itemMap["id"].(float64)
-			orderModel.Items = append(orderModel.Items, &model.OrderItem{
-				ID:        fmt.Sprintf("%d", int(itemID)),
-				OrderID:   int(itemMap["order_id"].(float64)),
-				ProductID: int(itemMap["product_id"].(float64)),
-				Quantity:  int(itemMap["quantity"].(float64)),
-				Price:     itemMap["price"].(float64),
-			})
+		for i := range shipment.Items {
+			shipmentModel.Items = append(shipmentModel.Items, &shipment.Items[i])
 		}
-
-		orders = append(orders, orderModel)
+		orderModel.Shipments = append(orderModel.Shipments, shipmentModel)
 	}
 
-	return orders, nil
+	return orderModel
 }
 
-func (r *Resolver) Order(ctx context.Context, id string) (*model.Order, error) {
-	url := fmt.Sprintf("%s/orders/%s", getOrderServiceURL(), id)
-
-	req, err := http.NewRequest("GET", url, nil)
+func (r *Resolver) PlaceOrder(ctx context.Context, input model.OrderInput) (*model.OrderResponse, error) {
+	result, err := clients.Orders.Place(ctx, input)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to place order: %w", err)
 	}
 
-	req.Header.Set("Authorization", getAuthHeader(ctx))
+	return &model.OrderResponse{
+		Message: result.Message,
+		OrderID: strconv.Itoa(result.OrderID),
+	}, nil
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+func (r *Resolver) OrderQuote(ctx context.Context, input model.OrderInput) (*model.PriceQuote, error) {
+	quote, err := clients.Orders.Quote(ctx, input)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to quote order: %w", err)
 	}
-	defer resp.Body.Close()
+	return quote, nil
+}
 
-	if resp.StatusCode == http.StatusNotFound {
-		return nil, nil
-	}
+func (r *Resolver) Cart(ctx context.Context, cartID *string) (*model.Cart, error) {
+	return doCartRequest(ctx, http.MethodGet, "/cart", cartID, nil)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to retrieve order: %s", resp.Status)
-	}
+func (r *Resolver) AddToCart(ctx context.Context, input model.AddToCartInput) (*model.Cart, error) {
+	return doCartRequest(ctx, http.MethodPost, "/cart/items", input.CartID, map[string]int{
+		"product_id": input.ProductID,
+		"quantity":   input.Quantity,
+	})
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
+func (r *Resolver) MergeCart(ctx context.Context, cartID string) (*model.Cart, error) {
+	return doCartRequest(ctx, http.MethodPost, "/cart/merge", nil, map[string]string{"cart_id": cartID})
+}
 
-	var result map[string]map[string]interface{}
-	err = json.Unmarshal(body, &result)
+func (r *Resolver) Checkout(ctx context.Context, shippingAddressID *int, couponCode *string) (*model.OrderResponse, error) {
+	result, err := clients.Orders.Checkout(ctx, map[string]interface{}{
+		"shipping_address_id": shippingAddressID,
+		"coupon_code":         couponCode,
+	})
 	if err != nil {
-		return nil, err
-	}
-
-	orderData, ok := result["order"]
-	if !ok {
-		return nil, fmt.Errorf("unexpected response format")
-	}
-
-	idFloat, ok := orderData["id"].(float64)
-	if !ok {
-		return nil, fmt.Errorf("unexpected type for order id")
-	}
-
-	order := &model.Order{
-		ID:        fmt.Sprintf("%d", int(idFloat)),
-		UserID:    int(orderData["user_id"].(float64)),
-		Status:    orderData["status"].(string),
-		Total:     orderData["total"].(float64),
-		CreatedAt: orderData["created_at"].(string),
+		return nil, fmt.Errorf("failed to checkout: %w", err)
 	}
 
-	// Parsing items
-	items := orderData["items"].([]interface{})
-	for _, item := range items {
-		itemMap := item.(map[string]interface{})
-		itemID, _ := itemMap["id"].(float64)
-		order.Items = append(order.Items, &model.OrderItem{
-			ID:        fmt.Sprintf("%d", int(itemID)),
-			OrderID:   int(itemMap["order_id"].(float64)),
-			ProductID: int(itemMap["product_id"].(float64)),
-			Quantity:  int(itemMap["quantity"].(float64)),
-			Price:     itemMap["price"].(float64),
-		})
-	}
-
-	return order, nil
+	return &model.OrderResponse{
+		Message: result.Message,
+		OrderID: strconv.Itoa(result.OrderID),
+	}, nil
 }
 
-func (r *Resolver) PlaceOrder(ctx context.Context, input model.OrderInput) (*model.OrderResponse, error) {
-	url := fmt.Sprintf("%s/orders", getOrderServiceURL())
-
-	orderData, err := json.Marshal(input)
+// Helper function to call a cart endpoint of the Order Service
+func doCartRequest(ctx context.Context, method, path string, cartID *string, payload interface{}) (*model.Cart, error) {
+	cart, err := clients.Orders.Cart(ctx, method, path, cartID, payload)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("cart request failed: %w", err)
 	}
+	return cart, nil
+}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(orderData))
+// Relationship Resolver Implementation
+func (r *Resolver) OrderUser(ctx context.Context, order *model.Order) (*model.User, error) {
+	user, err := loadersFor(ctx).UserByID.Load(ctx, dataloader.StringKey(strconv.Itoa(order.UserID)))()
 	if err != nil {
 		return nil, err
 	}
+	return user.(*model.User), nil
+}
 
-	req.Header.Set("Content-Type", "application/json")
-
-	// Get JWT token from context
-	authHeader := getAuthHeader(ctx)
-	if authHeader == "" {
-		return nil, fmt.Errorf("unauthorized: missing Authorization header")
-	}
-
-	req.Header.Set("Authorization", authHeader)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
+func (r *Resolver) OrderItemProduct(ctx context.Context, item *model.OrderItem) (*model.Product, error) {
+	product, err := loadersFor(ctx).ProductByID.Load(ctx, dataloader.StringKey(strconv.Itoa(item.ProductID)))()
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	return product.(*model.Product), nil
+}
 
-	var apiResponse map[string]interface{}
+// The Order Service only lets the user and admins list a user's orders
+func (r *Resolver) UserOrders(ctx context.Context, user *model.User) ([]*model.Order, error) {
+	return fetchOrderList(ctx, url.Values{"user_id": {user.ID}})
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return nil, err
-		}
+func (r *Resolver) UserOrdersConnection(ctx context.Context, user *model.User, limit *int, cursor *string) (*model.OrderConnection, error) {
+	return fetchOrders(ctx, url.Values{"user_id": {user.ID}}, limit, cursor)
+}
 
-		err = json.Unmarshal(body, &apiResponse)
-		if err != nil {
-			return nil, err
+// orderStatusEvents maps the order events to the status they leave the order
+// in; order_shipped carries the status itself since shipping can be partial
+var orderStatusEvents = map[string]string{
+	"order_shipped":     "",
+	"order_cancelled":   "Cancelled",
+	"payment_succeeded": "Paid",
+	"payment_failed":    "Payment Failed",
+}
+
+// Subscription Resolver Implementation
+func (r *Resolver) OrderStatusChanged(ctx context.Context, orderID string) (<-chan *model.OrderStatusUpdate, error) {
+	principal := auth.PrincipalFrom(ctx)
+	if principal == nil {
+		return nil, auth.Unauthenticated(ctx)
+	}
+
+	// The Order Service only returns orders the caller may see
+	order, err := r.OrderByID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		return nil, fmt.Errorf("order not found")
+	}
+
+	routingKeys := []string{}
+	for routingKey := range orderStatusEvents {
+		routingKeys = append(routingKeys, routingKey)
+	}
+	feed, unsubscribe, err := events.Subscribe(routingKeys...)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := make(chan *model.OrderStatusUpdate, 1)
+	go func() {
+		defer close(updates)
+		defer unsubscribe()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event := <-feed:
+				var data struct {
+					OrderID        int    `json:"order_id"`
+					UserID         int    `json:"user_id"`
+					Status         string `json:"status"`
+					Carrier        string `json:"carrier"`
+					TrackingNumber string `json:"tracking_number"`
+				}
+				if err := json.Unmarshal(event.Body, &data); err != nil {
+					log.Printf("Failed to parse %s event: %v", event.RoutingKey, err)
+					continue
+				}
+				if strconv.Itoa(data.OrderID) != order.ID {
+					continue
+				}
+				// Only the owner and admins see the order's events
+				if data.UserID != principal.UserID && !principal.HasRole(auth.RoleAdmin) {
+					continue
+				}
+
+				update := &model.OrderStatusUpdate{
+					OrderID: order.ID,
+					Status:  orderStatusEvents[event.RoutingKey],
+					Event:   event.RoutingKey,
+				}
+				if event.RoutingKey == "order_shipped" {
+					update.Status = data.Status
+					update.Carrier = &data.Carrier
+					update.TrackingNumber = &data.TrackingNumber
+				}
+
+				select {
+				case updates <- update:
+				case <-ctx.Done():
+					return
+				}
+			}
 		}
+	}()
 
-		message, _ := apiResponse["error"].(string)
-		return nil, fmt.Errorf("failed to place order: %s", message)
-	}
+	return updates, nil
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+func (r *Resolver) ProductInventoryChanged(ctx context.Context, productID string) (<-chan *model.InventoryUpdate, error) {
+	if _, err := strconv.Atoi(productID); err != nil {
+		return nil, fmt.Errorf("invalid product ID")
 	}
 
-	err = json.Unmarshal(body, &apiResponse)
+	feed, unsubscribe, err := events.Subscribe("inventory_updated")
 	if err != nil {
 		return nil, err
 	}
 
-	message, exists := apiResponse["message"].(string)
-	if !exists {
-		return nil, fmt.Errorf("unexpected response format: 'message' key not found")
-	}
+	updates := make(chan *model.InventoryUpdate, 1)
+	go func() {
+		defer close(updates)
+		defer unsubscribe()
 
-	orderID, _ := apiResponse["order_id"].(float64)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event := <-feed:
+				var data struct {
+					ProductID    int `json:"product_id"`
+					NewInventory int `json:"new_inventory"`
+				}
+				if err := json.Unmarshal(event.Body, &data); err != nil {
+					log.Printf("Failed to parse %s event: %v", event.RoutingKey, err)
+					continue
+				}
+				if strconv.Itoa(data.ProductID) != productID {
+					continue
+				}
 
-	return &model.OrderResponse{
-		Message: message,
-		OrderID: fmt.Sprintf("%d", int(orderID)),
-	}, nil
-}
+				select {
+				case updates <- &model.InventoryUpdate{ProductID: productID, Inventory: data.NewInventory}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
 
-func getOrderServiceURL() string {
-	url := os.Getenv("ORDER_SERVICE_URL")
-	if url == "" {
-		url = "http://order-service:8083"
-	}
-	return url
+	return updates, nil
 }
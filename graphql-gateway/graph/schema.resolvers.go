@@ -12,7 +12,7 @@ func (r *queryResolver) Users(ctx context.Context) ([]*model.User, error) {
 
 // User is the resolver for the user field.
 func (r *queryResolver) User(ctx context.Context, id string) (*model.User, error) {
-	return r.Resolver.User(ctx, id)
+	return r.Resolver.UserByID(ctx, id)
 }
 
 // Products is the resolver for the products field.
@@ -26,13 +26,33 @@ func (r *queryResolver) Product(ctx context.Context, id string) (*model.Product,
 }
 
 // Orders is the resolver for the orders field.
-func (r *queryResolver) Orders(ctx context.Context) ([]*model.Order, error) {
-	return r.Resolver.Orders(ctx)
+func (r *queryResolver) Orders(ctx context.Context, status []string, from *string, to *string) ([]*model.Order, error) {
+	return r.Resolver.Orders(ctx, status, from, to)
+}
+
+// OrdersConnection is the resolver for the ordersConnection field.
+func (r *queryResolver) OrdersConnection(ctx context.Context, status []string, from *string, to *string, limit *int, cursor *string) (*model.OrderConnection, error) {
+	return r.Resolver.OrdersConnection(ctx, status, from, to, limit, cursor)
 }
 
 // Order is the resolver for the order field.
 func (r *queryResolver) Order(ctx context.Context, id string) (*model.Order, error) {
-	return r.Resolver.Order(ctx, id)
+	return r.Resolver.OrderByID(ctx, id)
+}
+
+// OrderQuote is the resolver for the orderQuote field.
+func (r *queryResolver) OrderQuote(ctx context.Context, input model.OrderInput) (*model.PriceQuote, error) {
+	return r.Resolver.OrderQuote(ctx, input)
+}
+
+// AdminOrders is the resolver for the adminOrders field.
+func (r *queryResolver) AdminOrders(ctx context.Context, filter *model.AdminOrderFilter, sort *string, page *int, pageSize *int) (*model.AdminOrderPage, error) {
+	return r.Resolver.AdminOrders(ctx, filter, sort, page, pageSize)
+}
+
+// Cart is the resolver for the cart field.
+func (r *queryResolver) Cart(ctx context.Context, cartID *string) (*model.Cart, error) {
+	return r.Resolver.Cart(ctx, cartID)
 }
 
 // RegisterUser is the resolver for the registerUser field.
@@ -40,21 +60,107 @@ func (r *mutationResolver) RegisterUser(ctx context.Context, input model.Registe
 	return r.Resolver.RegisterUser(ctx, input)
 }
 
+// Login is the resolver for the login field.
+func (r *mutationResolver) Login(ctx context.Context, input model.LoginInput) (*model.AuthPayload, error) {
+	return r.Resolver.Login(ctx, input)
+}
+
+// UpdateProfile is the resolver for the updateProfile field.
+func (r *mutationResolver) UpdateProfile(ctx context.Context, input model.UpdateProfileInput) (*model.UserPayload, error) {
+	return r.Resolver.UpdateProfile(ctx, input)
+}
+
 // CreateProduct is the resolver for the createProduct field.
 func (r *mutationResolver) CreateProduct(ctx context.Context, input model.ProductInput) (*model.ProductResponse, error) {
 	return r.Resolver.CreateProduct(ctx, input)
 }
 
+// UpdateProduct is the resolver for the updateProduct field.
+func (r *mutationResolver) UpdateProduct(ctx context.Context, id string, input model.UpdateProductInput) (*model.ProductPayload, error) {
+	return r.Resolver.UpdateProduct(ctx, id, input)
+}
+
+// DeleteProduct is the resolver for the deleteProduct field.
+func (r *mutationResolver) DeleteProduct(ctx context.Context, id string) (*model.ProductPayload, error) {
+	return r.Resolver.DeleteProduct(ctx, id)
+}
+
 // PlaceOrder is the resolver for the placeOrder field.
 func (r *mutationResolver) PlaceOrder(ctx context.Context, input model.OrderInput) (*model.OrderResponse, error) {
 	return r.Resolver.PlaceOrder(ctx, input)
 }
 
+// CancelOrder is the resolver for the cancelOrder field.
+func (r *mutationResolver) CancelOrder(ctx context.Context, id string, reason *string) (*model.CancelOrderResponse, error) {
+	return r.Resolver.CancelOrder(ctx, id, reason)
+}
+
+// AddToCart is the resolver for the addToCart field.
+func (r *mutationResolver) AddToCart(ctx context.Context, input model.AddToCartInput) (*model.Cart, error) {
+	return r.Resolver.AddToCart(ctx, input)
+}
+
+// MergeCart is the resolver for the mergeCart field.
+func (r *mutationResolver) MergeCart(ctx context.Context, cartID string) (*model.Cart, error) {
+	return r.Resolver.MergeCart(ctx, cartID)
+}
+
+// Checkout is the resolver for the checkout field.
+func (r *mutationResolver) Checkout(ctx context.Context, shippingAddressID *int, couponCode *string) (*model.OrderResponse, error) {
+	return r.Resolver.Checkout(ctx, shippingAddressID, couponCode)
+}
+
+// OrderStatusChanged is the resolver for the orderStatusChanged field.
+func (r *subscriptionResolver) OrderStatusChanged(ctx context.Context, orderID string) (<-chan *model.OrderStatusUpdate, error) {
+	return r.Resolver.OrderStatusChanged(ctx, orderID)
+}
+
+// ProductInventoryChanged is the resolver for the productInventoryChanged field.
+func (r *subscriptionResolver) ProductInventoryChanged(ctx context.Context, productID string) (<-chan *model.InventoryUpdate, error) {
+	return r.Resolver.ProductInventoryChanged(ctx, productID)
+}
+
+// User is the resolver for the user field.
+func (r *orderResolver) User(ctx context.Context, obj *model.Order) (*model.User, error) {
+	return r.Resolver.OrderUser(ctx, obj)
+}
+
+// Product is the resolver for the product field.
+func (r *orderItemResolver) Product(ctx context.Context, obj *model.OrderItem) (*model.Product, error) {
+	return r.Resolver.OrderItemProduct(ctx, obj)
+}
+
+// Orders is the resolver for the orders field.
+func (r *userResolver) Orders(ctx context.Context, obj *model.User) ([]*model.Order, error) {
+	return r.Resolver.UserOrders(ctx, obj)
+}
+
+// OrdersConnection is the resolver for the ordersConnection field.
+func (r *userResolver) OrdersConnection(ctx context.Context, obj *model.User, limit *int, cursor *string) (*model.OrderConnection, error) {
+	return r.Resolver.UserOrdersConnection(ctx, obj, limit, cursor)
+}
+
 // Mutation returns MutationResolver implementation.
 func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
 
 // Query returns QueryResolver implementation.
 func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
 
+// Subscription returns SubscriptionResolver implementation.
+func (r *Resolver) Subscription() SubscriptionResolver { return &subscriptionResolver{r} }
+
+// Order returns OrderResolver implementation.
+func (r *Resolver) Order() OrderResolver { return &orderResolver{r} }
+
+// OrderItem returns OrderItemResolver implementation.
+func (r *Resolver) OrderItem() OrderItemResolver { return &orderItemResolver{r} }
+
+// User returns UserResolver implementation.
+func (r *Resolver) User() UserResolver { return &userResolver{r} }
+
 type mutationResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
+type subscriptionResolver struct{ *Resolver }
+type orderResolver struct{ *Resolver }
+type orderItemResolver struct{ *Resolver }
+type userResolver struct{ *Resolver }
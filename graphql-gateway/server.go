@@ -2,22 +2,39 @@ package main
 
 import (
 	"context"
+	"graphql-gateway/auth"
 	"graphql-gateway/cache"
+	"graphql-gateway/clients"
+	"graphql-gateway/events"
+	"graphql-gateway/extensions"
 	"graphql-gateway/graph"
+	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/go-chi/chi"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/vektah/gqlparser/v2/ast"
 )
 
 const defaultPort = "8080"
 
 func main() {
-	// Initialize Redis client
+	// Initialize Redis client; the gateway also runs without Redis
 	cache.InitRedis()
 
+	// Evict cached users and products when the services change them, and
+	// feed subscriptions; the gateway also starts without RabbitMQ
+	events.Init()
+	defer events.Close()
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = defaultPort
@@ -25,27 +42,108 @@ func main() {
 
 	router := chi.NewRouter()
 
-	// Middleware to add Authorization header to context
+	// Middleware to add Authorization and Idempotency-Key headers to context
 	router.Use(authorizationMiddleware)
 
-	// GraphQL handler
-	srv := handler.NewDefaultServer(graph.NewExecutableSchema(graph.Config{Resolvers: &graph.Resolver{}}))
+	// Per-request DataLoaders for relationship fields
+	router.Use(graph.LoaderMiddleware)
+
+	// GraphQL handler; the same setup as handler.NewDefaultServer, with the
+	// WebSocket transport authenticating subscriptions on connection init
+	config := graph.Config{Resolvers: &graph.Resolver{}, Complexity: graph.NewComplexityRoot()}
+	config.Directives.Auth = graph.Auth
+	config.Directives.HasRole = graph.HasRole
+	srv := handler.New(graph.NewExecutableSchema(config))
+	srv.AddTransport(transport.Websocket{
+		KeepAlivePingInterval: 10 * time.Second,
+		InitFunc:              websocketInit,
+	})
+	srv.AddTransport(transport.Options{})
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.AddTransport(transport.MultipartForm{})
+	srv.SetQueryCache(lru.New[*ast.QueryDocument](1000))
+
+	// Errors from the services carry a code extension such as NOT_FOUND
+	srv.SetErrorPresenter(graph.ErrorPresenter)
+
+	// In allow-list mode only the queries of the manifest run and the schema
+	// cannot be introspected; otherwise clients may introspect it and register
+	// queries as automatic persisted queries
+	if getEnv("PERSISTED_QUERIES_ONLY", "false") == "true" {
+		allowList, err := extensions.LoadAllowList(getEnv("PERSISTED_QUERIES_FILE", "persisted-queries.json"))
+		if err != nil {
+			log.Fatalf("Failed to load persisted queries: %v", err)
+		}
+		srv.Use(allowList)
+	} else {
+		srv.Use(extension.Introspection{})
+		srv.Use(extension.AutomaticPersistedQuery{
+			Cache: cache.PersistedQueries{TTL: 24 * time.Hour},
+		})
+	}
+
+	// Bound the backend calls a single operation can trigger
+	srv.Use(extension.FixedComplexityLimit(getEnvInt("GRAPHQL_COMPLEXITY_LIMIT", 1000)))
+	srv.Use(extensions.DepthLimit{Max: getEnvInt("GRAPHQL_DEPTH_LIMIT", 10)})
 
 	router.Handle("/", playground.Handler("GraphQL playground", "/query"))
 	router.Handle("/query", srv)
 
+	// Prometheus metrics endpoint
+	router.Handle("/metrics", promhttp.Handler())
+
 	http.ListenAndServe(":"+port, router)
 }
 
 func authorizationMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Extract the Authorization header from the request
-		authHeader := r.Header.Get("Authorization")
+		// Validate the JWT token, if any, and add the caller to the context.
+		// A caller with an invalid token is anonymous; fields that need a
+		// signed-in caller report why the token was rejected.
+		ctx := authenticate(r.Context(), r.Header.Get("Authorization"))
 
-		// Add it to the context
-		ctx := context.WithValue(r.Context(), "Authorization", authHeader)
+		// Mutations forward the client's idempotency key to the services
+		ctx = clients.WithIdempotencyKey(ctx, r.Header.Get("Idempotency-Key"))
 
 		// Pass the updated context to the next handler
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// websocketInit takes the JWT token from the connection_init payload, as
+// browsers cannot set headers on WebSocket requests. Like HTTP requests,
+// connections without a valid token can use public subscriptions only.
+func websocketInit(ctx context.Context, initPayload transport.InitPayload) (context.Context, *transport.InitPayload, error) {
+	return authenticate(ctx, initPayload.Authorization()), nil, nil
+}
+
+// authenticate adds the caller of an Authorization header to the context,
+// or the reason its token was rejected
+func authenticate(ctx context.Context, authHeader string) context.Context {
+	principal, err := auth.Authenticate(authHeader)
+	if err != nil {
+		log.Printf("Rejected token: %v", err)
+		return auth.WithAuthError(ctx, err)
+	}
+	if principal != nil {
+		ctx = auth.WithPrincipal(ctx, principal)
+	}
+	return ctx
+}
+
+func getEnv(key, defaultValue string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,139 @@
+package cart
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// Carts are Redis hashes mapping product IDs to quantities
+const (
+	userCartTTL      = 30 * 24 * time.Hour
+	anonymousCartTTL = 7 * 24 * time.Hour
+)
+
+var RedisClient *redis.Client
+
+func Init() {
+	redisAddr := fmt.Sprintf("%s:%s", getEnv("REDIS_HOST", "localhost"), getEnv("REDIS_PORT", "6379"))
+	RedisClient = redis.NewClient(&redis.Options{
+		Addr:     redisAddr,
+		Password: getEnv("REDIS_PASSWORD", ""),
+		DB:       0,
+	})
+
+	var err error
+	maxRetries := 12
+	for retries := 0; retries < maxRetries; retries++ {
+		_, err = RedisClient.Ping().Result()
+		if err == nil {
+			log.Println("Successfully connected to Redis")
+			return
+		}
+		log.Printf("Failed to connect to Redis: %v", err)
+		time.Sleep(5 * time.Second)
+		log.Println("Retrying Redis connection...")
+	}
+	log.Fatalf("Could not connect to Redis after %d attempts", maxRetries)
+}
+
+// UserKey is the cart of a signed-in user
+func UserKey(userID int) string {
+	return fmt.Sprintf("cart:user:%d", userID)
+}
+
+// AnonymousKey is a guest cart identified by the client-held cart ID
+func AnonymousKey(cartID string) string {
+	return "cart:anon:" + cartID
+}
+
+func ttl(key string) time.Duration {
+	if strings.HasPrefix(key, "cart:anon:") {
+		return anonymousCartTTL
+	}
+	return userCartTTL
+}
+
+// Get returns the quantities in the cart keyed by product ID
+func Get(key string) (map[int]int, error) {
+	fields, err := RedisClient.HGetAll(key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	items := make(map[int]int, len(fields))
+	for field, value := range fields {
+		productID, err := strconv.Atoi(field)
+		if err != nil {
+			continue
+		}
+		quantity, err := strconv.Atoi(value)
+		if err != nil || quantity <= 0 {
+			continue
+		}
+		items[productID] = quantity
+	}
+	return items, nil
+}
+
+// AddItem increases the quantity of a product and returns the new quantity
+func AddItem(key string, productID, quantity int) (int, error) {
+	pipe := RedisClient.TxPipeline()
+	newQuantity := pipe.HIncrBy(key, strconv.Itoa(productID), int64(quantity))
+	pipe.Expire(key, ttl(key))
+	if _, err := pipe.Exec(); err != nil {
+		return 0, err
+	}
+	return int(newQuantity.Val()), nil
+}
+
+// SetItem sets the quantity of a product, removing it when quantity is 0
+func SetItem(key string, productID, quantity int) error {
+	if quantity <= 0 {
+		return RemoveItem(key, productID)
+	}
+	pipe := RedisClient.TxPipeline()
+	pipe.HSet(key, strconv.Itoa(productID), quantity)
+	pipe.Expire(key, ttl(key))
+	_, err := pipe.Exec()
+	return err
+}
+
+func RemoveItem(key string, productID int) error {
+	return RedisClient.HDel(key, strconv.Itoa(productID)).Err()
+}
+
+func Clear(key string) error {
+	return RedisClient.Del(key).Err()
+}
+
+// Merge moves every item of the cart at from into the cart at to, adding up
+// quantities of products present in both, and deletes the source cart.
+func Merge(from, to string) error {
+	items, err := Get(from)
+	if err != nil {
+		return err
+	}
+
+	pipe := RedisClient.TxPipeline()
+	for productID, quantity := range items {
+		pipe.HIncrBy(to, strconv.Itoa(productID), int64(quantity))
+	}
+	pipe.Expire(to, ttl(to))
+	pipe.Del(from)
+	_, err = pipe.Exec()
+	return err
+}
+
+func getEnv(key, defaultValue string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
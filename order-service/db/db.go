@@ -63,10 +63,125 @@ func createTables() {
         quantity INT NOT NULL,
         price DECIMAL(10,2) NOT NULL
     );
+    CREATE INDEX IF NOT EXISTS orders_user_id_idx ON orders (user_id, id);
+    CREATE INDEX IF NOT EXISTS order_items_order_id_idx ON order_items (order_id);
+    CREATE TABLE IF NOT EXISTS payments (
+        id SERIAL PRIMARY KEY,
+        order_id INT NOT NULL REFERENCES orders(id),
+        provider VARCHAR(50) NOT NULL,
+        provider_ref VARCHAR(255) NOT NULL,
+        status VARCHAR(50) NOT NULL,
+        amount DECIMAL(10,2) NOT NULL,
+        captured_amount DECIMAL(10,2) NOT NULL DEFAULT 0,
+        refunded_amount DECIMAL(10,2) NOT NULL DEFAULT 0,
+        failure_reason TEXT NOT NULL DEFAULT '',
+        created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+        updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+        UNIQUE (provider, provider_ref)
+    );
+    CREATE INDEX IF NOT EXISTS payments_order_id_idx ON payments (order_id);
+    CREATE UNIQUE INDEX IF NOT EXISTS payments_order_id_active_idx ON payments (order_id) WHERE status <> 'failed';
+    ALTER TABLE orders ADD COLUMN IF NOT EXISTS shipping_address JSONB;
+    CREATE TABLE IF NOT EXISTS shipments (
+        id SERIAL PRIMARY KEY,
+        order_id INT NOT NULL REFERENCES orders(id),
+        carrier VARCHAR(50) NOT NULL,
+        tracking_number VARCHAR(100) NOT NULL,
+        status VARCHAR(50) NOT NULL,
+        shipped_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+        delivered_at TIMESTAMP WITH TIME ZONE
+    );
+    CREATE INDEX IF NOT EXISTS shipments_order_id_idx ON shipments (order_id);
+    CREATE TABLE IF NOT EXISTS shipment_items (
+        shipment_id INT NOT NULL REFERENCES shipments(id),
+        order_item_id INT NOT NULL REFERENCES order_items(id),
+        quantity INT NOT NULL,
+        PRIMARY KEY (shipment_id, order_item_id)
+    );
+    CREATE TABLE IF NOT EXISTS coupons (
+        id SERIAL PRIMARY KEY,
+        code VARCHAR(50) UNIQUE NOT NULL,
+        type VARCHAR(20) NOT NULL,
+        value DECIMAL(10,2) NOT NULL DEFAULT 0,
+        min_order_value DECIMAL(10,2) NOT NULL DEFAULT 0,
+        max_uses INT,
+        max_uses_per_user INT,
+        starts_at TIMESTAMP WITH TIME ZONE,
+        expires_at TIMESTAMP WITH TIME ZONE,
+        product_ids INT[] NOT NULL DEFAULT '{}',
+        categories TEXT[] NOT NULL DEFAULT '{}',
+        active BOOLEAN NOT NULL DEFAULT TRUE,
+        created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+    );
+    CREATE TABLE IF NOT EXISTS coupon_redemptions (
+        coupon_id INT NOT NULL REFERENCES coupons(id),
+        order_id INT NOT NULL REFERENCES orders(id),
+        user_id INT NOT NULL,
+        redeemed_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+        PRIMARY KEY (coupon_id, order_id)
+    );
+    CREATE INDEX IF NOT EXISTS coupon_redemptions_user_idx ON coupon_redemptions (coupon_id, user_id);
+    ALTER TABLE orders ADD COLUMN IF NOT EXISTS subtotal DECIMAL(10,2);
+    ALTER TABLE orders ADD COLUMN IF NOT EXISTS discount_total DECIMAL(10,2) NOT NULL DEFAULT 0;
+    ALTER TABLE orders ADD COLUMN IF NOT EXISTS coupon_code VARCHAR(50);
+    ALTER TABLE orders ADD COLUMN IF NOT EXISTS free_shipping BOOLEAN NOT NULL DEFAULT FALSE;
+    UPDATE orders SET subtotal = total WHERE subtotal IS NULL;
+    ALTER TABLE orders ALTER COLUMN subtotal SET NOT NULL;
+    ALTER TABLE order_items ADD COLUMN IF NOT EXISTS discount DECIMAL(10,2) NOT NULL DEFAULT 0;
+    ALTER TABLE order_items ADD COLUMN IF NOT EXISTS tax DECIMAL(10,2) NOT NULL DEFAULT 0;
+    ALTER TABLE orders ADD COLUMN IF NOT EXISTS tax_total DECIMAL(10,2) NOT NULL DEFAULT 0;
+    ALTER TABLE orders ADD COLUMN IF NOT EXISTS shipping_total DECIMAL(10,2) NOT NULL DEFAULT 0;
+    ALTER TABLE orders ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMP WITH TIME ZONE;
+    ALTER TABLE orders ADD COLUMN IF NOT EXISTS cancel_reason TEXT;
+    CREATE TABLE IF NOT EXISTS tax_rules (
+        id SERIAL PRIMARY KEY,
+        country VARCHAR(2) NOT NULL,
+        region VARCHAR(100) NOT NULL DEFAULT '',
+        tax_class VARCHAR(50) NOT NULL,
+        rate DECIMAL(6,4) NOT NULL,
+        UNIQUE (country, region, tax_class)
+    );
+    CREATE TABLE IF NOT EXISTS shipping_rates (
+        id SERIAL PRIMARY KEY,
+        country VARCHAR(2) NOT NULL DEFAULT '',
+        max_weight_grams INT,
+        rate DECIMAL(10,2) NOT NULL
+    );
+    CREATE TABLE IF NOT EXISTS returns (
+        id SERIAL PRIMARY KEY,
+        order_id INT NOT NULL REFERENCES orders(id),
+        user_id INT NOT NULL,
+        status VARCHAR(50) NOT NULL,
+        refund_amount DECIMAL(10,2) NOT NULL DEFAULT 0,
+        note TEXT NOT NULL DEFAULT '',
+        created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+        updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+    );
+    CREATE INDEX IF NOT EXISTS returns_order_id_idx ON returns (order_id);
+    CREATE TABLE IF NOT EXISTS return_items (
+        id SERIAL PRIMARY KEY,
+        return_id INT NOT NULL REFERENCES returns(id),
+        order_item_id INT NOT NULL REFERENCES order_items(id),
+        quantity INT NOT NULL,
+        reason VARCHAR(50) NOT NULL,
+        comment TEXT NOT NULL DEFAULT '',
+        accepted BOOLEAN,
+        restock BOOLEAN NOT NULL DEFAULT FALSE,
+        restocked_at TIMESTAMP WITH TIME ZONE,
+        refund_amount DECIMAL(10,2) NOT NULL DEFAULT 0,
+        UNIQUE (return_id, order_item_id)
+    );
+    CREATE TABLE IF NOT EXISTS payment_webhook_events (
+        provider VARCHAR(50) NOT NULL,
+        event_id VARCHAR(255) NOT NULL,
+        event_type VARCHAR(100) NOT NULL,
+        received_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+        PRIMARY KEY (provider, event_id)
+    );
     `
 	_, err := DB.Exec(query)
 	if err != nil {
-		log.Fatalf("Failed to create orders and order_items tables: %v", err)
+		log.Fatalf("Failed to create order tables: %v", err)
 	}
 }
 
@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+	"order-service/db"
+	"order-service/models"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// adminOrderSorts maps the accepted sort keys to their columns
+var adminOrderSorts = map[string]string{
+	"id":         "o.id",
+	"created_at": "o.created_at",
+	"total":      "o.total",
+	"status":     "o.status",
+	"user_id":    "o.user_id",
+}
+
+// GetAdminOrders lists orders across all users, filtered like
+// ExportAdminOrders and paginated with page/page_size
+func GetAdminOrders(c *gin.Context) {
+	// Authentication
+	username, exists := c.Get("username")
+	if !exists || username != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	where, args, err := adminOrderFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	orderBy, err := adminOrderSort(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := positiveQueryInt(c, "page", 1)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	pageSize, err := positiveQueryInt(c, "page_size", defaultPageSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+
+	var totalCount int
+	if err := db.DB.QueryRow(`SELECT COUNT(*) FROM orders o `+where, args...).Scan(&totalCount); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count orders"})
+		return
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM orders o %s ORDER BY %s LIMIT $%d OFFSET $%d`,
+		orderColumns, where, orderBy, len(args)+1, len(args)+2)
+	rows, err := db.DB.Query(query, append(args, pageSize, (page-1)*pageSize)...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve orders"})
+		return
+	}
+	defer rows.Close()
+
+	orders := []models.Order{}
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan order"})
+			return
+		}
+		orders = append(orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve orders"})
+		return
+	}
+
+	if err := attachOrderDetails(orders); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve order items"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"orders":      orders,
+		"page":        page,
+		"page_size":   pageSize,
+		"total_count": totalCount,
+	})
+}
+
+// ExportAdminOrders streams every order matching the filters as CSV, one row
+// per order
+func ExportAdminOrders(c *gin.Context) {
+	// Authentication
+	username, exists := c.Get("username")
+	if !exists || username != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	where, args, err := adminOrderFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	orderBy, err := adminOrderSort(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := `SELECT o.id, o.user_id, o.status, o.created_at, o.subtotal, o.discount_total, o.tax_total, o.shipping_total,
+	o.total, COALESCE(o.coupon_code, ''), (SELECT COALESCE(SUM(quantity), 0) FROM order_items WHERE order_id = o.id)
+	FROM orders o ` + where + ` ORDER BY ` + orderBy
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve orders"})
+		return
+	}
+	defer rows.Close()
+
+	// The first row is read before anything is sent, so a failing query still
+	// gets an error response
+	var record []string
+	if rows.Next() {
+		record, err = scanExportRow(rows)
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+	if err != nil {
+		log.Printf("Failed to export orders: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve orders"})
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", `attachment; filename="orders.csv"`)
+	c.Status(http.StatusOK)
+
+	writer := csv.NewWriter(c.Writer)
+	writer.Write([]string{"id", "user_id", "status", "created_at", "subtotal", "discount_total", "tax_total",
+		"shipping_total", "total", "coupon_code", "item_count"})
+	for record != nil {
+		writer.Write(record)
+		record = nil
+		if rows.Next() {
+			if record, err = scanExportRow(rows); err != nil {
+				break
+			}
+		}
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+	writer.Flush()
+	if err == nil {
+		err = writer.Error()
+	}
+	if err != nil {
+		log.Printf("Failed to export orders: %v", err)
+		abortDownload(c)
+	}
+}
+
+// scanExportRow reads one row of the orders export as a CSV record
+func scanExportRow(rows *sql.Rows) ([]string, error) {
+	var id, userID, itemCount int
+	var status, createdAt, couponCode string
+	var subtotal, discountTotal, taxTotal, shippingTotal, total float64
+	err := rows.Scan(&id, &userID, &status, &createdAt, &subtotal, &discountTotal, &taxTotal, &shippingTotal,
+		&total, &couponCode, &itemCount)
+	if err != nil {
+		return nil, err
+	}
+	return []string{
+		strconv.Itoa(id), strconv.Itoa(userID), status, createdAt,
+		formatAmount(subtotal), formatAmount(discountTotal), formatAmount(taxTotal), formatAmount(shippingTotal),
+		formatAmount(total), couponCode, strconv.Itoa(itemCount),
+	}, nil
+}
+
+// abortDownload closes the connection of a response that is already being
+// sent, so the client sees a failed download rather than a complete but
+// truncated file. Panicking would not do, as gin's recovery middleware
+// still finishes the response.
+func abortDownload(c *gin.Context) {
+	conn, _, err := c.Writer.Hijack()
+	if err != nil {
+		log.Printf("Failed to abort download: %v", err)
+		return
+	}
+	conn.Close()
+}
+
+// adminOrderFilter builds the WHERE clause for the orders table, aliased o,
+// from the query string: the filters of orderFilter plus user_id,
+// product_id, coupon_code and min_total/max_total
+func adminOrderFilter(c *gin.Context) (string, []interface{}, error) {
+	filter := &orderFilter{}
+	if err := filter.parseCommon(c); err != nil {
+		return "", nil, err
+	}
+
+	for _, param := range []struct{ name, condition string }{
+		{"user_id", "o.user_id = $%d"},
+		{"product_id", "EXISTS (SELECT 1 FROM order_items oi WHERE oi.order_id = o.id AND oi.product_id = $%d)"},
+	} {
+		if value := c.Query(param.name); value != "" {
+			id, err := strconv.Atoi(value)
+			if err != nil {
+				return "", nil, fmt.Errorf("invalid %s", param.name)
+			}
+			filter.add(param.condition, id)
+		}
+	}
+
+	if couponCode := c.Query("coupon_code"); couponCode != "" {
+		filter.add("UPPER(o.coupon_code) = UPPER($%d)", couponCode)
+	}
+
+	for _, param := range []struct{ name, condition string }{
+		{"min_total", "o.total >= $%d"},
+		{"max_total", "o.total <= $%d"},
+	} {
+		if value := c.Query(param.name); value != "" {
+			amount, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return "", nil, fmt.Errorf("invalid %s", param.name)
+			}
+			filter.add(param.condition, amount)
+		}
+	}
+
+	return filter.where(), filter.args, nil
+}
+
+// adminOrderSort turns the sort parameter, a key of adminOrderSorts with an
+// optional "-" prefix for descending, into an ORDER BY list. The default is
+// newest first.
+func adminOrderSort(c *gin.Context) (string, error) {
+	sort := c.DefaultQuery("sort", "-created_at")
+	direction := "ASC"
+	if strings.HasPrefix(sort, "-") {
+		sort = sort[1:]
+		direction = "DESC"
+	}
+	column, ok := adminOrderSorts[sort]
+	if !ok {
+		return "", fmt.Errorf("invalid sort %q", sort)
+	}
+	// Break ties by ID so pages are stable
+	return fmt.Sprintf("%s %s, o.id %s", column, direction, direction), nil
+}
+
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
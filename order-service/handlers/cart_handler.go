@@ -0,0 +1,296 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"order-service/cart"
+	"order-service/models"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cartIDHeader identifies a guest cart; signed-in users always use their own cart
+const cartIDHeader = "X-Cart-ID"
+
+// OptionalAuthenticate authenticates the request when a token is present and
+// lets anonymous requests through otherwise.
+func OptionalAuthenticate(c *gin.Context) {
+	if c.GetHeader("Authorization") == "" {
+		c.Next()
+		return
+	}
+	Authenticate(c)
+}
+
+func GetCart(c *gin.Context) {
+	key, cartID, ok := cartKey(c, false)
+	if !ok {
+		// A guest without a cart simply has an empty one
+		c.JSON(http.StatusOK, gin.H{"cart": annotateCart("", map[int]int{})})
+		return
+	}
+
+	items, err := cart.Get(key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve cart"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"cart": annotateCart(cartID, items)})
+}
+
+func AddCartItem(c *gin.Context) {
+	var input models.CartItemInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Quantity <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Quantity must be positive"})
+		return
+	}
+	if _, ok := getProductDetails(input.ProductID); !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	key, cartID, _ := cartKey(c, true)
+	if _, err := cart.AddItem(key, input.ProductID, input.Quantity); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update cart"})
+		return
+	}
+
+	respondWithCart(c, key, cartID)
+}
+
+func UpdateCartItem(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Param("product_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	var input models.CartQuantityInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Quantity < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Quantity cannot be negative"})
+		return
+	}
+
+	key, cartID, ok := cartKey(c, false)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cart not found"})
+		return
+	}
+	if err := cart.SetItem(key, productID, input.Quantity); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update cart"})
+		return
+	}
+
+	respondWithCart(c, key, cartID)
+}
+
+func RemoveCartItem(c *gin.Context) {
+	productID, err := strconv.Atoi(c.Param("product_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	key, cartID, ok := cartKey(c, false)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cart not found"})
+		return
+	}
+	if err := cart.RemoveItem(key, productID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update cart"})
+		return
+	}
+
+	respondWithCart(c, key, cartID)
+}
+
+func ClearCart(c *gin.Context) {
+	key, _, ok := cartKey(c, false)
+	if ok {
+		if err := cart.Clear(key); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear cart"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Cart cleared successfully"})
+}
+
+// MergeCart moves a guest cart into the signed-in user's cart, typically
+// right after login.
+func MergeCart(c *gin.Context) {
+	// Authentication
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var input models.CartMergeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	key := cart.UserKey(userID.(int))
+	if err := cart.Merge(cart.AnonymousKey(input.CartID), key); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge cart"})
+		return
+	}
+
+	respondWithCart(c, key, "")
+}
+
+// Checkout turns the signed-in user's cart into an order through the same
+// flow as PlaceOrder and empties the cart on success.
+func Checkout(c *gin.Context) {
+	// Authentication
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	key := cart.UserKey(userID.(int))
+	items, err := cart.Get(key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve cart"})
+		return
+	}
+	if len(items) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cart is empty"})
+		return
+	}
+
+	annotated := annotateCart("", items)
+	if !annotated.Checkoutable {
+		c.JSON(http.StatusConflict, gin.H{"error": "Some items in the cart are unavailable", "cart": annotated})
+		return
+	}
+
+	var checkout models.CheckoutInput
+	if err := c.ShouldBindJSON(&checkout); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	address, orderErr := resolveShippingAddress(c.GetHeader("Authorization"), checkout.ShippingAddressID)
+	if orderErr != nil {
+		c.JSON(orderErr.Status, gin.H{"error": orderErr.Message})
+		return
+	}
+
+	input := models.OrderInput{CouponCode: checkout.CouponCode}
+	for _, item := range annotated.Items {
+		input.Items = append(input.Items, models.OrderItemInput{
+			ProductID: item.ProductID,
+			Quantity:  item.Quantity,
+		})
+	}
+
+	orderID, orderErr := createOrder(userID.(int), input, address)
+	if orderErr != nil {
+		c.JSON(orderErr.Status, gin.H{"error": orderErr.Message})
+		return
+	}
+
+	if err := cart.Clear(key); err != nil {
+		// The order exists; a stale cart is only an inconvenience
+		c.JSON(http.StatusOK, gin.H{"message": "Order placed successfully", "order_id": orderID, "warning": "Failed to clear cart"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Order placed successfully", "order_id": orderID})
+}
+
+// cartKey resolves the cart of the request: the user's cart when signed in,
+// otherwise the guest cart named by the X-Cart-ID header. When create is set
+// and the guest has no cart yet, a new cart ID is generated.
+func cartKey(c *gin.Context, create bool) (key string, cartID string, ok bool) {
+	if userID, exists := c.Get("user_id"); exists {
+		return cart.UserKey(userID.(int)), "", true
+	}
+
+	cartID = strings.TrimSpace(c.GetHeader(cartIDHeader))
+	if cartID == "" {
+		if !create {
+			return "", "", false
+		}
+		cartID = newCartID()
+	}
+	return cart.AnonymousKey(cartID), cartID, true
+}
+
+func respondWithCart(c *gin.Context, key, cartID string) {
+	items, err := cart.Get(key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve cart"})
+		return
+	}
+
+	if cartID != "" {
+		c.Header(cartIDHeader, cartID)
+	}
+	c.JSON(http.StatusOK, gin.H{"cart": annotateCart(cartID, items)})
+}
+
+// annotateCart prices the cart from the live product catalog and flags lines
+// whose product is gone or does not have enough stock.
+func annotateCart(cartID string, items map[int]int) models.Cart {
+	result := models.Cart{CartID: cartID, Items: []models.CartItem{}, Checkoutable: len(items) > 0}
+
+	productIDs := make([]int, 0, len(items))
+	for productID := range items {
+		productIDs = append(productIDs, productID)
+	}
+	sort.Ints(productIDs)
+
+	for _, productID := range productIDs {
+		item := models.CartItem{ProductID: productID, Quantity: items[productID]}
+		product, ok := getProductDetails(productID)
+		switch {
+		case !ok:
+			item.Issue = "Product is no longer available"
+		case product.Inventory < item.Quantity:
+			item.Name = product.Name
+			item.UnitPrice = product.Price
+			item.AvailableQuantity = product.Inventory
+			item.Issue = "Insufficient inventory"
+		default:
+			item.Name = product.Name
+			item.UnitPrice = product.Price
+			item.AvailableQuantity = product.Inventory
+			item.Available = true
+		}
+		if !item.Available {
+			result.Checkoutable = false
+		}
+
+		item.LineTotal = item.UnitPrice * float64(item.Quantity)
+		result.Subtotal += item.LineTotal
+		result.ItemCount += item.Quantity
+		result.Items = append(result.Items, item)
+	}
+
+	return result
+}
+
+func newCartID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
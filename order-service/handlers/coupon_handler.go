@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"order-service/db"
+	"order-service/models"
+	"order-service/promotions"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+func GetCoupons(c *gin.Context) {
+	// Authentication
+	username, exists := c.Get("username")
+	if !exists || username != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	query := `SELECT ` + promotions.Columns + ` FROM coupons ORDER BY id`
+	rows, err := db.DB.Query(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve coupons"})
+		return
+	}
+	defer rows.Close()
+
+	coupons := []models.Coupon{}
+	for rows.Next() {
+		coupon, err := promotions.Scan(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan coupon"})
+			return
+		}
+		coupons = append(coupons, coupon)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"coupons": coupons})
+}
+
+func CreateCoupon(c *gin.Context) {
+	// Authentication
+	username, exists := c.Get("username")
+	if !exists || username != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	var input models.CouponInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if message := validateCoupon(&input); message != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return
+	}
+
+	query := `INSERT INTO coupons (code, type, value, min_order_value, max_uses, max_uses_per_user, starts_at, expires_at,
+	product_ids, categories, active) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING ` + promotions.Columns
+	coupon, err := promotions.Scan(db.DB.QueryRow(query, input.Code, input.Type, input.Value, input.MinOrderValue,
+		input.MaxUses, input.MaxUsesPerUser, input.StartsAt, input.ExpiresAt, pq.Array(input.ProductIDs),
+		pq.Array(input.Categories), *input.Active))
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Coupon code already exists"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create coupon"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Coupon created successfully", "coupon": coupon})
+}
+
+// UpdateCoupon replaces a coupon's rules. Past redemptions keep the discount
+// they were given.
+func UpdateCoupon(c *gin.Context) {
+	// Authentication
+	username, exists := c.Get("username")
+	if !exists || username != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	couponID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid coupon ID"})
+		return
+	}
+
+	var input models.CouponInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if message := validateCoupon(&input); message != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return
+	}
+
+	query := `UPDATE coupons SET code = $1, type = $2, value = $3, min_order_value = $4, max_uses = $5, max_uses_per_user = $6,
+	starts_at = $7, expires_at = $8, product_ids = $9, categories = $10, active = $11 WHERE id = $12 RETURNING ` + promotions.Columns
+	coupon, err := promotions.Scan(db.DB.QueryRow(query, input.Code, input.Type, input.Value, input.MinOrderValue,
+		input.MaxUses, input.MaxUsesPerUser, input.StartsAt, input.ExpiresAt, pq.Array(input.ProductIDs),
+		pq.Array(input.Categories), *input.Active, couponID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Coupon not found"})
+		} else if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Coupon code already exists"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update coupon"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Coupon updated successfully", "coupon": coupon})
+}
+
+// DeleteCoupon deactivates a coupon. Redeemed coupons are referenced by
+// orders, so the row is kept.
+func DeleteCoupon(c *gin.Context) {
+	// Authentication
+	username, exists := c.Get("username")
+	if !exists || username != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	couponID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid coupon ID"})
+		return
+	}
+
+	result, err := db.DB.Exec(`UPDATE coupons SET active = FALSE WHERE id = $1`, couponID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deactivate coupon"})
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Coupon not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Coupon deactivated successfully"})
+}
+
+// validateCoupon normalizes the input and returns an error message, or ""
+// when the coupon is valid
+func validateCoupon(input *models.CouponInput) string {
+	input.Code = strings.ToUpper(strings.TrimSpace(input.Code))
+	if input.Active == nil {
+		active := true
+		input.Active = &active
+	}
+	if input.ProductIDs == nil {
+		input.ProductIDs = []int{}
+	}
+	if input.Categories == nil {
+		input.Categories = []string{}
+	}
+
+	switch input.Type {
+	case models.CouponTypePercentage:
+		if input.Value <= 0 || input.Value > 100 {
+			return "Percentage coupons need a value between 0 and 100"
+		}
+	case models.CouponTypeFixedAmount:
+		if input.Value <= 0 {
+			return "Fixed amount coupons need a positive value"
+		}
+	case models.CouponTypeFreeShipping:
+		input.Value = 0
+	default:
+		return "Type must be percentage, fixed_amount or free_shipping"
+	}
+
+	if input.MinOrderValue < 0 {
+		return "Minimum order value cannot be negative"
+	}
+	if (input.MaxUses != nil && *input.MaxUses <= 0) || (input.MaxUsesPerUser != nil && *input.MaxUsesPerUser <= 0) {
+		return "Usage limits must be positive"
+	}
+
+	var startsAt, expiresAt time.Time
+	var err error
+	if input.StartsAt != nil {
+		if startsAt, err = time.Parse(time.RFC3339, *input.StartsAt); err != nil {
+			return "starts_at must be an RFC 3339 timestamp"
+		}
+	}
+	if input.ExpiresAt != nil {
+		if expiresAt, err = time.Parse(time.RFC3339, *input.ExpiresAt); err != nil {
+			return "expires_at must be an RFC 3339 timestamp"
+		}
+	}
+	if input.StartsAt != nil && input.ExpiresAt != nil && !expiresAt.After(startsAt) {
+		return "expires_at must be after starts_at"
+	}
+	return ""
+}
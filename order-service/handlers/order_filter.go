@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// orderFilter collects the conditions of a WHERE clause on the orders table,
+// aliased o, numbering the placeholders as they are added
+type orderFilter struct {
+	conditions []string
+	args       []interface{}
+}
+
+// add appends a condition whose single %d verb becomes the placeholder of
+// value
+func (f *orderFilter) add(condition string, value interface{}) {
+	f.args = append(f.args, value)
+	f.conditions = append(f.conditions, fmt.Sprintf(condition, len(f.args)))
+}
+
+func (f *orderFilter) where() string {
+	if len(f.conditions) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(f.conditions, " AND ")
+}
+
+// parseCommon adds the filters every order listing accepts: status
+// (repeatable or comma separated) and from/to (RFC 3339 or YYYY-MM-DD, to is
+// inclusive)
+func (f *orderFilter) parseCommon(c *gin.Context) error {
+	statuses := []string{}
+	for _, value := range c.QueryArray("status") {
+		for _, status := range strings.Split(value, ",") {
+			if status = strings.TrimSpace(status); status != "" {
+				statuses = append(statuses, status)
+			}
+		}
+	}
+	if len(statuses) > 0 {
+		f.add("o.status = ANY($%d)", pq.Array(statuses))
+	}
+
+	if value := c.Query("from"); value != "" {
+		from, _, err := parseDateParam(value)
+		if err != nil {
+			return fmt.Errorf("invalid from, use RFC 3339 or YYYY-MM-DD")
+		}
+		f.add("o.created_at >= $%d", from)
+	}
+	if value := c.Query("to"); value != "" {
+		to, dateOnly, err := parseDateParam(value)
+		if err != nil {
+			return fmt.Errorf("invalid to, use RFC 3339 or YYYY-MM-DD")
+		}
+		if dateOnly {
+			// A plain date includes the whole day
+			f.add("o.created_at < $%d", to.AddDate(0, 0, 1))
+		} else {
+			f.add("o.created_at <= $%d", to)
+		}
+	}
+	return nil
+}
+
+// Order cursors are opaque to clients; they encode the ID of the last order
+// of the previous page.
+func encodeOrderCursor(orderID int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(orderID)))
+}
+
+func decodeOrderCursor(cursor string) (int, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(string(decoded))
+}
+
+func parseDateParam(value string) (t time.Time, dateOnly bool, err error) {
+	if t, err = time.Parse("2006-01-02", value); err == nil {
+		return t, true, nil
+	}
+	t, err = time.Parse(time.RFC3339, value)
+	return t, false, err
+}
+
+func positiveQueryInt(c *gin.Context, name string, defaultValue int) (int, error) {
+	value := c.Query(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%s must be a positive integer", name)
+	}
+	return n, nil
+}
@@ -1,11 +1,19 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"order-service/db"
 	"order-service/models"
+	"order-service/payments"
+	"order-service/pricing"
+	"order-service/promotions"
 	"order-service/rabbitmq"
 	"order-service/utils"
 
@@ -15,6 +23,7 @@ import (
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
 )
 
 func Authenticate(c *gin.Context) {
@@ -65,74 +74,208 @@ func PlaceOrder(c *gin.Context) {
 		return
 	}
 
-	// Calculate total and prepare order items
-	total := 0.0
-	orderItems := []models.OrderItem{}
-	for _, item := range input.Items {
+	address, orderErr := resolveShippingAddress(c.GetHeader("Authorization"), input.ShippingAddressID)
+	if orderErr != nil {
+		c.JSON(orderErr.Status, gin.H{"error": orderErr.Message})
+		return
+	}
+
+	orderID, orderErr := createOrder(userID.(int), input, address)
+	if orderErr != nil {
+		c.JSON(orderErr.Status, gin.H{"error": orderErr.Message})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Order placed successfully", "order_id": orderID})
+}
+
+// orderError is a failure of createOrder with the HTTP status to answer
+type orderError struct {
+	Status  int
+	Message string
+}
+
+// resolveShippingAddress fetches the address to ship to from user_service on
+// behalf of the caller. Without an addressID the user's default address is
+// used; a user with no addresses gets no shipping address. The default
+// address is optional, so if user_service cannot provide it the order is
+// placed without one instead of failing.
+func resolveShippingAddress(authHeader string, addressID *int) (*models.ShippingAddress, *orderError) {
+	address, orderErr := fetchShippingAddress(authHeader, addressID)
+	if orderErr != nil && addressID == nil {
+		log.Printf("Placing order without a shipping address: %s", orderErr.Message)
+		return nil, nil
+	}
+	return address, orderErr
+}
+
+// Helper function to fetch an address, or the default address when addressID
+// is nil, from user_service
+func fetchShippingAddress(authHeader string, addressID *int) (*models.ShippingAddress, *orderError) {
+	path := "default"
+	if addressID != nil {
+		path = strconv.Itoa(*addressID)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, utils.UserServiceURL+"/addresses/"+path, nil)
+	if err != nil {
+		return nil, &orderError{http.StatusInternalServerError, "Failed to look up shipping address"}
+	}
+	req.Header.Set("Authorization", authHeader)
+
+	resp, err := utils.HTTPClient.Do(req)
+	if err != nil {
+		log.Printf("Failed to fetch address from User Service: %v", err)
+		return nil, &orderError{http.StatusBadGateway, "Failed to look up shipping address"}
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound && addressID == nil:
+		return nil, nil
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, &orderError{http.StatusBadRequest, fmt.Sprintf("Shipping address %d not found", *addressID)}
+	case resp.StatusCode != http.StatusOK:
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		log.Printf("Failed to fetch address: %s", string(bodyBytes))
+		return nil, &orderError{http.StatusBadGateway, "Failed to look up shipping address"}
+	}
+
+	var responseData struct {
+		Address struct {
+			models.ShippingAddress
+			ID int `json:"id"`
+		} `json:"address"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&responseData); err != nil {
+		return nil, &orderError{http.StatusBadGateway, "Failed to parse shipping address"}
+	}
+
+	address := responseData.Address.ShippingAddress
+	address.AddressID = responseData.Address.ID
+	return &address, nil
+}
+
+// orderLines validates the items against the product catalog and returns
+// them as pricing lines
+func orderLines(items []models.OrderItemInput) ([]pricing.Line, *orderError) {
+	if len(items) == 0 {
+		return nil, &orderError{http.StatusBadRequest, "Order must contain at least one item"}
+	}
+
+	lines := []pricing.Line{}
+	for _, item := range items {
+		if item.Quantity <= 0 {
+			return nil, &orderError{http.StatusBadRequest, fmt.Sprintf("Quantity for product %d must be positive", item.ProductID)}
+		}
+
 		// Get product details
 		product, ok := getProductDetails(item.ProductID)
 		if !ok {
-			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Product %d not found", item.ProductID)})
-			return
+			return nil, &orderError{http.StatusBadRequest, fmt.Sprintf("Product %d not found", item.ProductID)}
 		}
 
 		// Check if requested quantity exceeds available inventory
 		if item.Quantity > product.Inventory {
-			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Product %d has insufficient inventory", item.ProductID)})
-			return
+			return nil, &orderError{http.StatusBadRequest, fmt.Sprintf("Product %d has insufficient inventory", item.ProductID)}
 		}
 
-		itemTotal := product.Price * float64(item.Quantity)
-		total += itemTotal
-		orderItems = append(orderItems, models.OrderItem{
-			ProductID: item.ProductID,
-			Quantity:  item.Quantity,
-			Price:     product.Price,
+		lines = append(lines, pricing.Line{
+			Line: promotions.Line{
+				ProductID: item.ProductID,
+				Category:  product.Category,
+				UnitPrice: product.Price,
+				Quantity:  item.Quantity,
+			},
+			TaxClass:    product.TaxClass,
+			WeightGrams: product.WeightGrams,
 		})
 	}
+	return lines, nil
+}
+
+// pricingError converts a failure of the pricing pipeline
+func pricingError(err error) *orderError {
+	if couponErr, ok := err.(*promotions.CouponError); ok {
+		return &orderError{http.StatusBadRequest, couponErr.Message}
+	}
+	log.Printf("Failed to price order: %v", err)
+	return &orderError{http.StatusInternalServerError, "Failed to calculate order price"}
+}
+
+// createOrder prices the items, stores the order with each price component
+// and a snapshot of the shipping address, redeems the coupon and emits the
+// "Order Placed" event. It is shared by PlaceOrder and cart checkout.
+func createOrder(userID int, input models.OrderInput, address *models.ShippingAddress) (int, *orderError) {
+	lines, orderErr := orderLines(input.Items)
+	if orderErr != nil {
+		return 0, orderErr
+	}
+
+	var addressJSON []byte
+	if address != nil {
+		var err error
+		addressJSON, err = json.Marshal(address)
+		if err != nil {
+			return 0, &orderError{http.StatusInternalServerError, "Failed to serialize shipping address"}
+		}
+	}
 
 	// Start transaction
 	tx, err := db.DB.Begin()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
-		return
+		return 0, &orderError{http.StatusInternalServerError, "Failed to start transaction"}
 	}
 
+	// Price inside the transaction so the coupon's usage limits hold
+	priced, err := pricing.Calculate(tx, userID, lines, input.CouponCode, address)
+	if err != nil {
+		tx.Rollback()
+		return 0, pricingError(err)
+	}
+	quote := priced.Quote
+
 	// Insert order
 	var orderID int
-	query := `INSERT INTO orders (user_id, status, total) VALUES ($1, $2, $3) RETURNING id`
-	err = tx.QueryRow(query, userID, "Placed", total).Scan(&orderID)
+	query := `INSERT INTO orders (user_id, status, subtotal, discount_total, tax_total, shipping_total, total, coupon_code,
+	free_shipping, shipping_address) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`
+	err = tx.QueryRow(query, userID, models.OrderStatusPlaced, quote.Subtotal, quote.DiscountTotal, quote.TaxTotal,
+		quote.ShippingTotal, quote.Total, quote.CouponCode, quote.FreeShipping, addressJSON).Scan(&orderID)
 	if err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order"})
-		return
+		return 0, &orderError{http.StatusInternalServerError, "Failed to create order"}
 	}
 
 	// Insert order items
-	for _, item := range orderItems {
-		query = `INSERT INTO order_items (order_id, product_id, quantity, price) VALUES ($1, $2, $3, $4)`
-		_, err = tx.Exec(query, orderID, item.ProductID, item.Quantity, item.Price)
+	for _, item := range quote.Items {
+		query = `INSERT INTO order_items (order_id, product_id, quantity, price, discount, tax) VALUES ($1, $2, $3, $4, $5, $6)`
+		_, err = tx.Exec(query, orderID, item.ProductID, item.Quantity, item.UnitPrice, item.Discount, item.Tax)
 		if err != nil {
 			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order items"})
-			return
+			return 0, &orderError{http.StatusInternalServerError, "Failed to create order items"}
+		}
+	}
+
+	if quote.CouponCode != nil {
+		if err := promotions.Redeem(tx, priced.Promotion.Coupon.ID, orderID, userID); err != nil {
+			tx.Rollback()
+			return 0, &orderError{http.StatusInternalServerError, "Failed to redeem coupon"}
 		}
 	}
 
 	// Commit transaction
 	err = tx.Commit()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
-		return
+		return 0, &orderError{http.StatusInternalServerError, "Failed to commit transaction"}
 	}
 
 	// Emit "Order Placed" event
 	orderEvent := models.OrderPlacedEvent{
 		OrderID: orderID,
-		UserID:  userID.(int),
+		UserID:  userID,
 		Items:   []models.OrderItemInfo{},
 	}
-	for _, item := range orderItems {
+	for _, item := range quote.Items {
 		orderEvent.Items = append(orderEvent.Items, models.OrderItemInfo{
 			ProductID: item.ProductID,
 			Quantity:  item.Quantity,
@@ -140,9 +283,58 @@ func PlaceOrder(c *gin.Context) {
 	}
 	rabbitmq.EmitOrderPlaced(orderEvent)
 
-	c.JSON(http.StatusOK, gin.H{"message": "Order placed successfully", "order_id": orderID})
+	return orderID, nil
+}
+
+// QuoteOrder prices an order exactly like PlaceOrder would, without creating
+// it or redeeming the coupon
+func QuoteOrder(c *gin.Context) {
+	// Authentication
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var input models.OrderInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	lines, orderErr := orderLines(input.Items)
+	if orderErr != nil {
+		c.JSON(orderErr.Status, gin.H{"error": orderErr.Message})
+		return
+	}
+
+	address, orderErr := resolveShippingAddress(c.GetHeader("Authorization"), input.ShippingAddressID)
+	if orderErr != nil {
+		c.JSON(orderErr.Status, gin.H{"error": orderErr.Message})
+		return
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	// A quote never writes, so always roll back
+	defer tx.Rollback()
+
+	priced, err := pricing.Calculate(tx, userID.(int), lines, input.CouponCode, address)
+	if err != nil {
+		orderErr := pricingError(err)
+		c.JSON(orderErr.Status, gin.H{"error": orderErr.Message})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"quote": priced.Quote})
 }
 
+// GetAllOrders lists the caller's orders, newest first, filtered by status
+// and from/to. Pages hold up to limit orders; next_cursor is passed as cursor
+// to get the following page.
 func GetAllOrders(c *gin.Context) {
 	// Authentication
 	userID, exists := c.Get("user_id")
@@ -151,8 +343,49 @@ func GetAllOrders(c *gin.Context) {
 		return
 	}
 
-	query := `SELECT id, user_id, status, total, created_at FROM orders WHERE user_id = $1`
-	rows, err := db.DB.Query(query, userID)
+	// Admins may list another user's orders with ?user_id=
+	if value := c.Query("user_id"); value != "" {
+		requestedID, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+			return
+		}
+		username, _ := c.Get("username")
+		if requestedID != userID && username != "admin" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+		userID = requestedID
+	}
+
+	filter := &orderFilter{}
+	filter.add("o.user_id = $%d", userID)
+	if err := filter.parseCommon(c); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit, err := positiveQueryInt(c, "limit", defaultPageSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		lastID, err := decodeOrderCursor(cursor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		filter.add("o.id < $%d", lastID)
+	}
+
+	// Fetch one extra order to know whether another page follows
+	query := fmt.Sprintf(`SELECT %s FROM orders o %s ORDER BY o.id DESC LIMIT $%d`, orderColumns, filter.where(), len(filter.args)+1)
+	rows, err := db.DB.Query(query, append(filter.args, limit+1)...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve orders"})
 		return
@@ -161,25 +394,36 @@ func GetAllOrders(c *gin.Context) {
 
 	orders := []models.Order{}
 	for rows.Next() {
-		var order models.Order
-		err := rows.Scan(&order.ID, &order.UserID, &order.Status, &order.Total, &order.CreatedAt)
+		order, err := scanOrder(rows)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan order"})
 			return
 		}
+		orders = append(orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve orders"})
+		return
+	}
 
-		// Get order items
-		items, err := getOrderItems(order.ID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve order items"})
-			return
-		}
-		order.Items = items
+	hasMore := len(orders) > limit
+	if hasMore {
+		orders = orders[:limit]
+	}
 
-		orders = append(orders, order)
+	// Items and shipments of the whole page in one query each
+	if err := attachOrderDetails(orders); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve order items"})
+		return
+	}
+
+	var nextCursor *string
+	if hasMore {
+		cursor := encodeOrderCursor(orders[len(orders)-1].ID)
+		nextCursor = &cursor
 	}
 
-	c.JSON(http.StatusOK, gin.H{"orders": orders})
+	c.JSON(http.StatusOK, gin.H{"orders": orders, "next_cursor": nextCursor, "has_more": hasMore})
 }
 
 func GetOrderByID(c *gin.Context) {
@@ -189,6 +433,7 @@ func GetOrderByID(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
+	username, _ := c.Get("username")
 
 	idParam := c.Param("id")
 	orderID, err := strconv.Atoi(idParam)
@@ -197,20 +442,13 @@ func GetOrderByID(c *gin.Context) {
 		return
 	}
 
-	query := `SELECT id, user_id, status, total, created_at FROM orders WHERE id = $1`
-	var order models.Order
-	err = db.DB.QueryRow(query, orderID).Scan(&order.ID, &order.UserID, &order.Status, &order.Total, &order.CreatedAt)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve order"})
-		}
+	order, ok := loadOrderHeader(c, orderID)
+	if !ok {
 		return
 	}
 
-	// Check if the order belongs to the authenticated user
-	if order.UserID != userID {
+	// Check if the order belongs to the authenticated user; admins see all
+	if order.UserID != userID && username != "admin" {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
 		return
 	}
@@ -223,27 +461,370 @@ func GetOrderByID(c *gin.Context) {
 	}
 	order.Items = items
 
+	// Get shipments
+	shipments, err := getOrderShipments(order.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve shipments"})
+		return
+	}
+	order.Shipments = shipments
+
 	c.JSON(http.StatusOK, gin.H{"order": order})
 }
 
+// cancellableStatuses are the order statuses in which nothing has shipped yet
+var cancellableStatuses = map[string]bool{
+	models.OrderStatusPlaced:        true,
+	models.OrderStatusPaymentFailed: true,
+	models.OrderStatusPaid:          true,
+}
+
+// CancelOrder cancels an order that has not shipped and then refunds captured
+// money. If the refund fails the order stays cancelled and the request fails
+// with 502. The order_cancelled event lets product-service return the stock.
+func CancelOrder(c *gin.Context) {
+	// Authentication
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	username, _ := c.Get("username")
+
+	orderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var input models.CancelOrderInput
+	if err := c.ShouldBindJSON(&input); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Lock the order so it cannot ship or be cancelled twice meanwhile
+	order, err := scanOrder(tx.QueryRow(`SELECT `+orderColumns+` FROM orders WHERE id = $1 FOR UPDATE`, orderID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve order"})
+		}
+		return
+	}
+	if order.UserID != userID && username != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+	if !cancellableStatuses[order.Status] {
+		c.JSON(http.StatusConflict, gin.H{"error": "Order cannot be cancelled in status " + order.Status})
+		return
+	}
+
+	var cancelReason *string
+	if input.Reason != "" {
+		cancelReason = &input.Reason
+	}
+	_, err = tx.Exec(`UPDATE orders SET status = $1, cancelled_at = CURRENT_TIMESTAMP, cancel_reason = $2 WHERE id = $3`,
+		models.OrderStatusCancelled, cancelReason, orderID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel order"})
+		return
+	}
+
+	// Give the coupon use back
+	if _, err := tx.Exec(`DELETE FROM coupon_redemptions WHERE order_id = $1`, orderID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release coupon"})
+		return
+	}
+
+	// The cancellation is committed before any money moves, so a failed or
+	// retried request can never refund an order that is still open
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	cancelledBy := fmt.Sprintf("user:%v", userID)
+	if username == "admin" {
+		cancelledBy = "admin"
+	}
+	refunded, refundErr := refundCancelledOrder(c.Request.Context(), order)
+	emitOrderCancelled(order, input.Reason, cancelledBy, refunded)
+
+	if refundErr != nil {
+		log.Printf("Order %d was cancelled but could not be refunded: %v", orderID, refundErr)
+		status := http.StatusBadGateway
+		if refundErr == payments.ErrRefundInProgress || refundErr == payments.ErrVoidInProgress {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": "Order cancelled but the refund failed: " + refundErr.Error(), "order_id": orderID})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Order cancelled", "order_id": orderID, "refunded_amount": refunded})
+}
+
+// RejectOrderStock cancels an order product-service could not take the stock
+// for, e.g. because a product ran out or was archived. Orders that have moved
+// on, e.g. because they were cancelled meanwhile, are left alone, so
+// redelivered events are harmless.
+func RejectOrderStock(event models.OrderStockRejectedEvent) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		log.Printf("Failed to start transaction for order %d: %v", event.OrderID, err)
+		return
+	}
+	defer tx.Rollback()
+
+	order, err := scanOrder(tx.QueryRow(`SELECT `+orderColumns+` FROM orders WHERE id = $1 FOR UPDATE`, event.OrderID))
+	if err != nil {
+		log.Printf("Failed to retrieve order %d: %v", event.OrderID, err)
+		return
+	}
+	if !cancellableStatuses[order.Status] {
+		log.Printf("Order %d with rejected stock is %s and is not cancelled", order.ID, order.Status)
+		return
+	}
+
+	reasons := []string{}
+	for _, item := range event.Items {
+		switch item.Reason {
+		case models.RejectReasonProductNotFound:
+			reasons = append(reasons, fmt.Sprintf("Product %d is not available", item.ProductID))
+		case models.RejectReasonFailed:
+			reasons = append(reasons, fmt.Sprintf("Stock for product %d could not be reserved", item.ProductID))
+		default:
+			reasons = append(reasons, fmt.Sprintf("Insufficient stock for product %d", item.ProductID))
+		}
+	}
+	reason := strings.Join(reasons, "; ")
+	_, err = tx.Exec(`UPDATE orders SET status = $1, cancelled_at = CURRENT_TIMESTAMP, cancel_reason = $2 WHERE id = $3`,
+		models.OrderStatusCancelled, reason, order.ID)
+	if err != nil {
+		log.Printf("Failed to cancel order %d: %v", order.ID, err)
+		return
+	}
+	if _, err := tx.Exec(`DELETE FROM coupon_redemptions WHERE order_id = $1`, order.ID); err != nil {
+		log.Printf("Failed to release coupon of order %d: %v", order.ID, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to commit cancellation of order %d: %v", order.ID, err)
+		return
+	}
+
+	refunded, err := refundCancelledOrder(context.Background(), order)
+	if err != nil {
+		log.Printf("Order %d was cancelled but could not be refunded: %v", order.ID, err)
+	}
+	emitOrderCancelled(order, reason, "system", refunded)
+	log.Printf("Order %d cancelled: %s", order.ID, reason)
+}
+
+// refundCancelledOrder refunds whatever was captured for a cancelled order and
+// not refunded yet, and voids a payment that was only authorized so the funds
+// are no longer held. The payment is checked even for Placed orders, as
+// payment_succeeded may still be queued; a payment still pending with the
+// provider is released by the request paying it. A refund or void that fails
+// can be retried through POST /orders/{id}/payments/refund.
+func refundCancelledOrder(ctx context.Context, order models.Order) (float64, error) {
+	payment, err := payments.LatestForOrder(order.ID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if payment.Status == payments.StatusAuthorized {
+		return 0, voidPayment(ctx, payment)
+	}
+	if payment.Status != payments.StatusCaptured && payment.Status != payments.StatusPartiallyRefunded {
+		return 0, nil
+	}
+	refundable := payment.CapturedAmount - payment.RefundedAmount
+	if refundable <= 0.005 {
+		return 0, nil
+	}
+
+	payment, err = payments.Refund(ctx, payment, refundable)
+	if err != nil {
+		return 0, err
+	}
+	if payment.Status != payments.StatusRefunded {
+		return 0, errors.New("refund declined: " + payment.FailureReason)
+	}
+
+	rabbitmq.EmitPaymentEvent("payment_refunded", models.PaymentEvent{
+		OrderID:   order.ID,
+		UserID:    order.UserID,
+		PaymentID: payment.ID,
+		Amount:    refundable,
+	})
+	return refundable, nil
+}
+
+// voidPayment releases the authorization of a cancelled order's payment
+func voidPayment(ctx context.Context, payment models.Payment) error {
+	payment, err := payments.Void(ctx, payment)
+	if err != nil {
+		return err
+	}
+	if payment.Status != payments.StatusVoided {
+		return errors.New("void declined: " + payment.FailureReason)
+	}
+	return nil
+}
+
+// emitOrderCancelled publishes order_cancelled with the order's items, so
+// product-service returns their stock
+func emitOrderCancelled(order models.Order, reason, cancelledBy string, refunded float64) {
+	items, err := getOrderItems(order.ID)
+	if err != nil {
+		log.Printf("Failed to load items of cancelled order %d: %v", order.ID, err)
+	}
+	cancelEvent := models.OrderCancelledEvent{
+		OrderID:        order.ID,
+		UserID:         order.UserID,
+		Reason:         reason,
+		CancelledBy:    cancelledBy,
+		RefundedAmount: refunded,
+		Items:          []models.OrderItemInfo{},
+	}
+	for _, item := range items {
+		cancelEvent.Items = append(cancelEvent.Items, models.OrderItemInfo{
+			ProductID: item.ProductID,
+			Quantity:  item.Quantity,
+		})
+	}
+	rabbitmq.EmitOrderCancelled(cancelEvent)
+}
+
+const orderColumns = `id, user_id, status, subtotal, discount_total, tax_total, shipping_total, total, coupon_code, free_shipping,
+created_at, cancelled_at, cancel_reason, shipping_address`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanOrder(row rowScanner) (models.Order, error) {
+	var order models.Order
+	var addressJSON []byte
+	var couponCode, cancelledAt, cancelReason sql.NullString
+	err := row.Scan(&order.ID, &order.UserID, &order.Status, &order.Subtotal, &order.DiscountTotal, &order.TaxTotal, &order.ShippingTotal, &order.Total,
+		&couponCode, &order.FreeShipping, &order.CreatedAt, &cancelledAt, &cancelReason, &addressJSON)
+	if err != nil {
+		return order, err
+	}
+	if couponCode.Valid {
+		order.CouponCode = &couponCode.String
+	}
+	if cancelledAt.Valid {
+		order.CancelledAt = &cancelledAt.String
+	}
+	if cancelReason.Valid {
+		order.CancelReason = &cancelReason.String
+	}
+	if addressJSON != nil {
+		order.ShippingAddress = &models.ShippingAddress{}
+		err = json.Unmarshal(addressJSON, order.ShippingAddress)
+	}
+	return order, err
+}
+
+// getOrderHeader loads an order without its items
+func getOrderHeader(orderID int) (models.Order, error) {
+	query := `SELECT ` + orderColumns + ` FROM orders WHERE id = $1`
+	return scanOrder(db.DB.QueryRow(query, orderID))
+}
+
+// loadOrderHeader is getOrderHeader answering 404/500 itself; ok is false
+// when a response has been written
+func loadOrderHeader(c *gin.Context, orderID int) (models.Order, bool) {
+	order, err := getOrderHeader(orderID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve order"})
+		}
+		return order, false
+	}
+	return order, true
+}
+
 func getOrderItems(orderID int) ([]models.OrderItem, error) {
-	query := `SELECT id, order_id, product_id, quantity, price FROM order_items WHERE order_id = $1`
-	rows, err := db.DB.Query(query, orderID)
+	items, err := getItemsForOrders([]int{orderID})
+	if err != nil {
+		return nil, err
+	}
+	if items[orderID] == nil {
+		return []models.OrderItem{}, nil
+	}
+	return items[orderID], nil
+}
+
+// getItemsForOrders loads the items of several orders in one query, keyed by
+// order ID
+func getItemsForOrders(orderIDs []int) (map[int][]models.OrderItem, error) {
+	query := `SELECT id, order_id, product_id, quantity, price, discount, tax FROM order_items WHERE order_id = ANY($1) ORDER BY id`
+	rows, err := db.DB.Query(query, pq.Array(orderIDs))
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	items := []models.OrderItem{}
+	items := map[int][]models.OrderItem{}
 	for rows.Next() {
 		var item models.OrderItem
-		err := rows.Scan(&item.ID, &item.OrderID, &item.ProductID, &item.Quantity, &item.Price)
+		err := rows.Scan(&item.ID, &item.OrderID, &item.ProductID, &item.Quantity, &item.Price, &item.Discount, &item.Tax)
 		if err != nil {
 			return nil, err
 		}
-		items = append(items, item)
+		items[item.OrderID] = append(items[item.OrderID], item)
+	}
+	return items, rows.Err()
+}
+
+// attachOrderDetails fills in the items and shipments of a page of orders
+// with one query each
+func attachOrderDetails(orders []models.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+	orderIDs := make([]int, len(orders))
+	for i, order := range orders {
+		orderIDs[i] = order.ID
+	}
+
+	items, err := getItemsForOrders(orderIDs)
+	if err != nil {
+		return err
+	}
+	shipments, err := getShipmentsForOrders(orderIDs)
+	if err != nil {
+		return err
+	}
+
+	for i := range orders {
+		orders[i].Items = items[orders[i].ID]
+		if orders[i].Items == nil {
+			orders[i].Items = []models.OrderItem{}
+		}
+		orders[i].Shipments = shipments[orders[i].ID]
+		if orders[i].Shipments == nil {
+			orders[i].Shipments = []models.Shipment{}
+		}
 	}
-	return items, nil
+	return nil
 }
 
 func getProductDetails(productID int) (models.Product, bool) {
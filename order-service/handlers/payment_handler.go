@@ -0,0 +1,340 @@
+package handlers
+
+import (
+	"database/sql"
+	"io"
+	"log"
+	"net/http"
+	"order-service/models"
+	"order-service/payments"
+	"order-service/rabbitmq"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PayOrder charges the order total with the configured payment provider. The
+// order status follows from the payment_succeeded / payment_failed events.
+func PayOrder(c *gin.Context) {
+	// Authentication
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	orderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var input models.PaymentInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	order, ok := loadOrderHeader(c, orderID)
+	if !ok {
+		return
+	}
+	if order.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+	if order.Status != models.OrderStatusPlaced && order.Status != models.OrderStatusPaymentFailed {
+		c.JSON(http.StatusConflict, gin.H{"error": "Order cannot be paid in status " + order.Status})
+		return
+	}
+
+	// Only one active payment per order; Pay claims it before calling the
+	// provider, so concurrent requests cannot charge the order twice
+	capture := input.Capture == nil || *input.Capture
+	payment, err := payments.Pay(c.Request.Context(), orderID, order.Total, input.PaymentMethod, capture)
+	if err == payments.ErrPaymentExists {
+		latest, err := payments.LatestForOrder(orderID)
+		if err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Order already has a payment"})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": "Order already has a payment in status " + latest.Status})
+		return
+	}
+	if err != nil {
+		log.Printf("Payment for order %d failed: %v", orderID, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Payment provider error"})
+		return
+	}
+
+	// The order is not locked while the provider is called. A cancellation
+	// meanwhile did not see the pending payment, so release it here.
+	if payment.Status != payments.StatusFailed {
+		current, err := getOrderHeader(orderID)
+		if err == nil && current.Status == models.OrderStatusCancelled {
+			if _, err := refundCancelledOrder(c.Request.Context(), current); err != nil {
+				log.Printf("Payment for cancelled order %d could not be released: %v", orderID, err)
+			}
+			c.JSON(http.StatusConflict, gin.H{"error": "Order was cancelled while it was being paid; the payment was released"})
+			return
+		}
+	}
+
+	emitPaymentOutcome(order, payment)
+	if payment.Status == payments.StatusFailed {
+		c.JSON(http.StatusPaymentRequired, gin.H{"error": "Payment declined", "payment": payment})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Payment " + payment.Status, "payment": payment})
+}
+
+func GetOrderPayments(c *gin.Context) {
+	// Authentication
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	username, _ := c.Get("username")
+
+	orderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	order, ok := loadOrderHeader(c, orderID)
+	if !ok {
+		return
+	}
+	if order.UserID != userID && username != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	orderPayments, err := payments.ForOrder(orderID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve payments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"payments": orderPayments})
+}
+
+// CapturePayment captures a payment that was only authorized
+func CapturePayment(c *gin.Context) {
+	// Authentication
+	username, exists := c.Get("username")
+	if !exists || username != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	orderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	order, ok := loadOrderHeader(c, orderID)
+	if !ok {
+		return
+	}
+	if order.Status == models.OrderStatusCancelled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Order is cancelled"})
+		return
+	}
+
+	payment, ok := loadLatestPayment(c, orderID)
+	if !ok {
+		return
+	}
+	if payment.Status != payments.StatusAuthorized {
+		c.JSON(http.StatusConflict, gin.H{"error": "Payment cannot be captured in status " + payment.Status})
+		return
+	}
+
+	payment, err = payments.Capture(c.Request.Context(), payment)
+	if err != nil {
+		log.Printf("Capture for order %d failed: %v", orderID, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Payment provider error"})
+		return
+	}
+
+	emitPaymentOutcome(order, payment)
+	c.JSON(http.StatusOK, gin.H{"message": "Payment " + payment.Status, "payment": payment})
+}
+
+func RefundPayment(c *gin.Context) {
+	// Authentication
+	username, exists := c.Get("username")
+	if !exists || username != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	orderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var input models.RefundInput
+	if err := c.ShouldBindJSON(&input); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	order, ok := loadOrderHeader(c, orderID)
+	if !ok {
+		return
+	}
+
+	payment, ok := loadLatestPayment(c, orderID)
+	if !ok {
+		return
+	}
+	// A cancelled order's authorization is released instead, in case voiding
+	// it failed on cancellation
+	if payment.Status == payments.StatusAuthorized && order.Status == models.OrderStatusCancelled {
+		err := voidPayment(c.Request.Context(), payment)
+		if err == payments.ErrVoidInProgress {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Printf("Void for order %d failed: %v", orderID, err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Void failed: " + err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Payment voided"})
+		return
+	}
+	if payment.Status != payments.StatusCaptured && payment.Status != payments.StatusPartiallyRefunded {
+		c.JSON(http.StatusConflict, gin.H{"error": "Payment cannot be refunded in status " + payment.Status})
+		return
+	}
+
+	// Refund whatever is left unless an amount is given
+	refundable := payment.CapturedAmount - payment.RefundedAmount
+	amount := input.Amount
+	if amount == 0 {
+		amount = refundable
+	}
+	if amount < 0 || amount > refundable+0.005 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Refund amount must be between 0 and the refundable amount"})
+		return
+	}
+
+	payment, err = payments.Refund(c.Request.Context(), payment, amount)
+	if err == payments.ErrRefundInProgress {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Refund for order %d failed: %v", orderID, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Payment provider error"})
+		return
+	}
+	if payment.Status != payments.StatusRefunded && payment.Status != payments.StatusPartiallyRefunded {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Refund declined: " + payment.FailureReason})
+		return
+	}
+
+	rabbitmq.EmitPaymentEvent("payment_refunded", models.PaymentEvent{
+		OrderID:   order.ID,
+		UserID:    order.UserID,
+		PaymentID: payment.ID,
+		Amount:    amount,
+	})
+	c.JSON(http.StatusOK, gin.H{"message": "Payment refunded", "payment": payment})
+}
+
+// PaymentWebhook receives asynchronous notifications from a payment provider.
+// Deliveries are deduplicated by event ID, so providers may safely retry.
+func PaymentWebhook(c *gin.Context) {
+	provider, err := payments.Provider(c.Param("provider"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown payment provider"})
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read webhook body"})
+		return
+	}
+
+	event, err := provider.ParseWebhook(c.Request.Header, body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook: " + err.Error()})
+		return
+	}
+
+	payment, duplicate, err := payments.ApplyWebhook(provider.Name(), event)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process webhook"})
+		}
+		return
+	}
+	if duplicate {
+		c.JSON(http.StatusOK, gin.H{"message": "Webhook already processed"})
+		return
+	}
+
+	order, err := getOrderHeader(payment.OrderID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve order"})
+		return
+	}
+
+	switch event.Type {
+	case payments.EventCaptured, payments.EventFailed:
+		emitPaymentOutcome(order, payment)
+	case payments.EventRefunded:
+		rabbitmq.EmitPaymentEvent("payment_refunded", models.PaymentEvent{
+			OrderID:   order.ID,
+			UserID:    order.UserID,
+			PaymentID: payment.ID,
+			Amount:    event.Amount,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook processed"})
+}
+
+// emitPaymentOutcome publishes payment_succeeded once money is captured and
+// payment_failed when the provider declined
+func emitPaymentOutcome(order models.Order, payment models.Payment) {
+	event := models.PaymentEvent{
+		OrderID:   order.ID,
+		UserID:    order.UserID,
+		PaymentID: payment.ID,
+		Amount:    payment.Amount,
+		Reason:    payment.FailureReason,
+	}
+
+	switch payment.Status {
+	case payments.StatusCaptured:
+		rabbitmq.EmitPaymentEvent("payment_succeeded", event)
+	case payments.StatusFailed:
+		rabbitmq.EmitPaymentEvent("payment_failed", event)
+	}
+}
+
+func loadLatestPayment(c *gin.Context, orderID int) (models.Payment, bool) {
+	payment, err := payments.LatestForOrder(orderID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order has no payment"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve payments"})
+		}
+		return payment, false
+	}
+	return payment, true
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"net/http"
+	"order-service/db"
+	"order-service/models"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+func GetTaxRules(c *gin.Context) {
+	// Authentication
+	username, exists := c.Get("username")
+	if !exists || username != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	rows, err := db.DB.Query(`SELECT id, country, region, tax_class, rate FROM tax_rules ORDER BY country, region, tax_class`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tax rules"})
+		return
+	}
+	defer rows.Close()
+
+	rules := []models.TaxRule{}
+	for rows.Next() {
+		var rule models.TaxRule
+		if err := rows.Scan(&rule.ID, &rule.Country, &rule.Region, &rule.TaxClass, &rule.Rate); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan tax rule"})
+			return
+		}
+		rules = append(rules, rule)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tax_rules": rules})
+}
+
+// CreateTaxRule adds a rule, or replaces the rate of the rule for the same
+// country, region and tax class
+func CreateTaxRule(c *gin.Context) {
+	// Authentication
+	username, exists := c.Get("username")
+	if !exists || username != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	var input models.TaxRule
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Rate < 0 || input.Rate >= 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Rate must be a fraction between 0 and 1, e.g. 0.19"})
+		return
+	}
+	input.Country = strings.ToUpper(input.Country)
+
+	query := `INSERT INTO tax_rules (country, region, tax_class, rate) VALUES ($1, $2, $3, $4)
+	ON CONFLICT (country, region, tax_class) DO UPDATE SET rate = EXCLUDED.rate RETURNING id`
+	err := db.DB.QueryRow(query, input.Country, input.Region, input.TaxClass, input.Rate).Scan(&input.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save tax rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tax rule saved successfully", "tax_rule": input})
+}
+
+func DeleteTaxRule(c *gin.Context) {
+	deletePricingRow(c, "tax_rules", "Tax rule")
+}
+
+func GetShippingRates(c *gin.Context) {
+	// Authentication
+	username, exists := c.Get("username")
+	if !exists || username != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	query := `SELECT id, country, max_weight_grams, rate FROM shipping_rates ORDER BY country, max_weight_grams NULLS LAST`
+	rows, err := db.DB.Query(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve shipping rates"})
+		return
+	}
+	defer rows.Close()
+
+	rates := []models.ShippingRate{}
+	for rows.Next() {
+		var rate models.ShippingRate
+		if err := rows.Scan(&rate.ID, &rate.Country, &rate.MaxWeightGrams, &rate.Rate); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan shipping rate"})
+			return
+		}
+		rates = append(rates, rate)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"shipping_rates": rates})
+}
+
+func CreateShippingRate(c *gin.Context) {
+	// Authentication
+	username, exists := c.Get("username")
+	if !exists || username != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	var input models.ShippingRate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Country != "" && len(input.Country) != 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Country must be a two-letter code, or empty for all countries"})
+		return
+	}
+	if input.Rate < 0 || (input.MaxWeightGrams != nil && *input.MaxWeightGrams <= 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Rate cannot be negative and max weight must be positive"})
+		return
+	}
+	input.Country = strings.ToUpper(input.Country)
+
+	query := `INSERT INTO shipping_rates (country, max_weight_grams, rate) VALUES ($1, $2, $3) RETURNING id`
+	err := db.DB.QueryRow(query, input.Country, input.MaxWeightGrams, input.Rate).Scan(&input.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save shipping rate"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Shipping rate created successfully", "shipping_rate": input})
+}
+
+func DeleteShippingRate(c *gin.Context) {
+	deletePricingRow(c, "shipping_rates", "Shipping rate")
+}
+
+// deletePricingRow deletes the row of a pricing table named by the :id param
+func deletePricingRow(c *gin.Context, table, name string) {
+	// Authentication
+	username, exists := c.Get("username")
+	if !exists || username != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + strings.ToLower(name) + " ID"})
+		return
+	}
+
+	result, err := db.DB.Exec(`DELETE FROM `+pq.QuoteIdentifier(table)+` WHERE id = $1`, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete " + strings.ToLower(name)})
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": name + " not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": name + " deleted successfully"})
+}
@@ -0,0 +1,654 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"order-service/db"
+	"order-service/models"
+	"order-service/payments"
+	"order-service/rabbitmq"
+	"order-service/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// returnTransitions lists, per target status, the statuses a return may move
+// from through UpdateReturnStatus
+var returnTransitions = map[string][]string{
+	models.ReturnStatusApproved: {models.ReturnStatusRequested},
+	models.ReturnStatusRejected: {models.ReturnStatusRequested, models.ReturnStatusApproved},
+	models.ReturnStatusReceived: {models.ReturnStatusApproved},
+}
+
+// RequestReturn opens a return for some lines of a delivered order within
+// the return window
+func RequestReturn(c *gin.Context) {
+	// Authentication
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	orderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var input models.ReturnInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(input.Items) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Return must contain at least one item"})
+		return
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Lock the order so concurrent requests cannot return the same units twice
+	var ownerID int
+	var status string
+	err = tx.QueryRow(`SELECT user_id, status FROM orders WHERE id = $1 FOR UPDATE`, orderID).Scan(&ownerID, &status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve order"})
+		}
+		return
+	}
+	if ownerID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+	if status != models.OrderStatusDelivered {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only delivered orders can be returned"})
+		return
+	}
+
+	var withinWindow bool
+	query := `SELECT COALESCE(MAX(delivered_at) >= CURRENT_TIMESTAMP - $2::int * INTERVAL '1 day', FALSE)
+	FROM shipments WHERE order_id = $1`
+	if err := tx.QueryRow(query, orderID, utils.ReturnWindowDays).Scan(&withinWindow); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve shipments"})
+		return
+	}
+	if !withinWindow {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("The %d day return window has passed", utils.ReturnWindowDays)})
+		return
+	}
+
+	returnable, productIDs, err := returnableQuantities(tx, orderID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve order items"})
+		return
+	}
+
+	listed := map[int]bool{}
+	for _, item := range input.Items {
+		if listed[item.OrderItemID] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Order item %d is listed more than once", item.OrderItemID)})
+			return
+		}
+		listed[item.OrderItemID] = true
+
+		left, ok := returnable[item.OrderItemID]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Order item %d does not belong to order %d", item.OrderItemID, orderID)})
+			return
+		}
+		if !models.ReturnReasons[item.Reason] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid return reason %q", item.Reason)})
+			return
+		}
+		if item.Quantity <= 0 || item.Quantity > left {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Quantity for order item %d must be between 1 and %d", item.OrderItemID, left)})
+			return
+		}
+	}
+
+	ret := models.Return{
+		OrderID: orderID,
+		UserID:  ownerID,
+		Status:  models.ReturnStatusRequested,
+		Items:   []models.ReturnItem{},
+	}
+	query = `INSERT INTO returns (order_id, user_id, status) VALUES ($1, $2, $3) RETURNING id, created_at, updated_at`
+	err = tx.QueryRow(query, orderID, ownerID, ret.Status).Scan(&ret.ID, &ret.CreatedAt, &ret.UpdatedAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create return"})
+		return
+	}
+
+	for _, item := range input.Items {
+		returnItem := models.ReturnItem{
+			OrderItemID: item.OrderItemID,
+			ProductID:   productIDs[item.OrderItemID],
+			Quantity:    item.Quantity,
+			Reason:      item.Reason,
+			Comment:     item.Comment,
+		}
+		query = `INSERT INTO return_items (return_id, order_item_id, quantity, reason, comment) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+		err = tx.QueryRow(query, ret.ID, item.OrderItemID, item.Quantity, item.Reason, item.Comment).Scan(&returnItem.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create return items"})
+			return
+		}
+		ret.Items = append(ret.Items, returnItem)
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Return requested", "return": ret})
+}
+
+func GetOrderReturns(c *gin.Context) {
+	// Authentication
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	username, _ := c.Get("username")
+
+	orderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	order, ok := loadOrderHeader(c, orderID)
+	if !ok {
+		return
+	}
+	if order.UserID != userID && username != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	returns, err := getReturns(`WHERE order_id = $1`, orderID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve returns"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"returns": returns})
+}
+
+// GetReturns lists the returns of all orders, optionally with one status
+func GetReturns(c *gin.Context) {
+	// Authentication
+	username, exists := c.Get("username")
+	if !exists || username != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	var returns []models.Return
+	var err error
+	if status := c.Query("status"); status != "" {
+		returns, err = getReturns(`WHERE status = $1`, status)
+	} else {
+		returns, err = getReturns(``)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve returns"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"returns": returns})
+}
+
+// UpdateReturnStatus approves, rejects or marks a return as received
+func UpdateReturnStatus(c *gin.Context) {
+	// Authentication
+	username, exists := c.Get("username")
+	if !exists || username != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	orderID, returnID, ok := returnParams(c)
+	if !ok {
+		return
+	}
+
+	var input models.ReturnStatusInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	allowedFrom, ok := returnTransitions[input.Status]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Status must be approved, rejected or received"})
+		return
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	ret, ok := lockReturn(c, tx, orderID, returnID)
+	if !ok {
+		return
+	}
+	if !containsStatus(allowedFrom, ret.Status) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Return cannot be %s in status %s", input.Status, ret.Status)})
+		return
+	}
+
+	note := ret.Note
+	if input.Note != "" {
+		note = input.Note
+	}
+	query := `UPDATE returns SET status = $1, note = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $3`
+	if _, err := tx.Exec(query, input.Status, note, returnID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update return"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	respondWithReturn(c, returnID, "Return "+input.Status)
+}
+
+// InspectReturn records which received items are accepted for a refund and
+// which go back into stock. The refund is each accepted unit's share of its
+// order line, after discounts and including tax; shipping is not refunded.
+func InspectReturn(c *gin.Context) {
+	// Authentication
+	username, exists := c.Get("username")
+	if !exists || username != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	orderID, returnID, ok := returnParams(c)
+	if !ok {
+		return
+	}
+
+	var input models.InspectReturnInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	ret, ok := lockReturn(c, tx, orderID, returnID)
+	if !ok {
+		return
+	}
+	if ret.Status != models.ReturnStatusReceived {
+		c.JSON(http.StatusConflict, gin.H{"error": "Return cannot be inspected in status " + ret.Status})
+		return
+	}
+
+	type returnLine struct {
+		id, productID, quantity, orderedQuantity int
+		price, discount, tax                     float64
+	}
+	query := `SELECT ri.id, ri.order_item_id, oi.product_id, ri.quantity, oi.quantity, oi.price, oi.discount, oi.tax
+	FROM return_items ri JOIN order_items oi ON oi.id = ri.order_item_id WHERE ri.return_id = $1`
+	rows, err := tx.Query(query, returnID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve return items"})
+		return
+	}
+	lines := map[int]returnLine{}
+	for rows.Next() {
+		var line returnLine
+		var orderItemID int
+		err := rows.Scan(&line.id, &orderItemID, &line.productID, &line.quantity, &line.orderedQuantity, &line.price, &line.discount, &line.tax)
+		if err != nil {
+			rows.Close()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan return item"})
+			return
+		}
+		lines[orderItemID] = line
+	}
+	rows.Close()
+
+	if len(input.Items) != len(lines) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Every returned item must be inspected exactly once"})
+		return
+	}
+
+	var refundTotal float64
+	restock := map[int]int{}
+	for _, item := range input.Items {
+		line, ok := lines[item.OrderItemID]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Order item %d is not part of return %d", item.OrderItemID, returnID)})
+			return
+		}
+		delete(lines, item.OrderItemID)
+		if item.Restock && !item.Accepted {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Order item %d must be accepted to be restocked", item.OrderItemID)})
+			return
+		}
+
+		var refund float64
+		if item.Accepted {
+			lineTotal := line.price*float64(line.orderedQuantity) - line.discount + line.tax
+			refund = math.Round(lineTotal/float64(line.orderedQuantity)*float64(line.quantity)*100) / 100
+			refundTotal += refund
+		}
+		if item.Restock {
+			restock[line.productID] += line.quantity
+		}
+
+		query = `UPDATE return_items SET accepted = $1, restock = $2, refund_amount = $3 WHERE id = $4`
+		if _, err := tx.Exec(query, item.Accepted, item.Restock, refund, line.id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update return items"})
+			return
+		}
+	}
+
+	note := ret.Note
+	if input.Note != "" {
+		note = input.Note
+	}
+	query = `UPDATE returns SET status = $1, refund_amount = $2, note = $3, updated_at = CURRENT_TIMESTAMP WHERE id = $4`
+	if _, err := tx.Exec(query, models.ReturnStatusInspected, math.Round(refundTotal*100)/100, note, returnID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update return"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	if len(restock) > 0 {
+		event := models.ReturnInspectedEvent{
+			ReturnID: returnID,
+			OrderID:  orderID,
+			UserID:   ret.UserID,
+			Items:    []models.OrderItemInfo{},
+		}
+		for productID, quantity := range restock {
+			event.Items = append(event.Items, models.OrderItemInfo{ProductID: productID, Quantity: quantity})
+		}
+		rabbitmq.EmitReturnInspected(event)
+	}
+
+	respondWithReturn(c, returnID, "Return inspected")
+}
+
+// RefundReturn pays back the refund amount of an inspected return
+func RefundReturn(c *gin.Context) {
+	// Authentication
+	username, exists := c.Get("username")
+	if !exists || username != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	orderID, returnID, ok := returnParams(c)
+	if !ok {
+		return
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	ret, ok := lockReturn(c, tx, orderID, returnID)
+	if !ok {
+		return
+	}
+	if ret.Status != models.ReturnStatusInspected {
+		c.JSON(http.StatusConflict, gin.H{"error": "Return cannot be refunded in status " + ret.Status})
+		return
+	}
+
+	var payment models.Payment
+	if ret.RefundAmount > 0 {
+		payment, ok = loadLatestPayment(c, orderID)
+		if !ok {
+			return
+		}
+		if payment.Status != payments.StatusCaptured && payment.Status != payments.StatusPartiallyRefunded {
+			c.JSON(http.StatusConflict, gin.H{"error": "Payment cannot be refunded in status " + payment.Status})
+			return
+		}
+		if ret.RefundAmount > payment.CapturedAmount-payment.RefundedAmount+0.005 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Refund amount exceeds the refundable amount of the payment"})
+			return
+		}
+	}
+
+	// The return is marked as refunding and committed before the provider is
+	// called, so a retried request cannot refund it a second time
+	query := `UPDATE returns SET status = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`
+	if _, err := tx.Exec(query, models.ReturnStatusRefunding, returnID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update return"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	if ret.RefundAmount > 0 {
+		payment, err = payments.Refund(c.Request.Context(), payment, ret.RefundAmount)
+		if err != nil && payment.Status == payments.StatusRefunding {
+			// The provider could not be reached and may have refunded; the
+			// return stays refunding until the payment is reconciled
+			log.Printf("Refund for return %d failed: %v", returnID, err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Payment provider error"})
+			return
+		}
+		if err != nil || (payment.Status != payments.StatusRefunded && payment.Status != payments.StatusPartiallyRefunded) {
+			// Nothing was refunded, so the refund can be retried
+			if _, updateErr := db.DB.Exec(query, models.ReturnStatusInspected, returnID); updateErr != nil {
+				log.Printf("Failed to put return %d back to inspected: %v", returnID, updateErr)
+			}
+			switch {
+			case err == payments.ErrRefundInProgress:
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			case err != nil:
+				log.Printf("Refund for return %d failed: %v", returnID, err)
+				c.JSON(http.StatusBadGateway, gin.H{"error": "Payment provider error"})
+			default:
+				c.JSON(http.StatusBadGateway, gin.H{"error": "Refund declined: " + payment.FailureReason})
+			}
+			return
+		}
+	}
+
+	if _, err := db.DB.Exec(query, models.ReturnStatusRefunded, returnID); err != nil {
+		log.Printf("Return %d was refunded %.2f but could not be updated: %v", returnID, ret.RefundAmount, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update return"})
+		return
+	}
+
+	if ret.RefundAmount > 0 {
+		rabbitmq.EmitPaymentEvent("payment_refunded", models.PaymentEvent{
+			OrderID:   orderID,
+			UserID:    ret.UserID,
+			PaymentID: payment.ID,
+			Amount:    ret.RefundAmount,
+		})
+	}
+
+	respondWithReturn(c, returnID, "Return refunded")
+}
+
+func returnParams(c *gin.Context) (orderID, returnID int, ok bool) {
+	orderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return 0, 0, false
+	}
+	returnID, err = strconv.Atoi(c.Param("return_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid return ID"})
+		return 0, 0, false
+	}
+	return orderID, returnID, true
+}
+
+func containsStatus(statuses []string, status string) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// returnableQuantities returns, per order item, how many units are not part
+// of a return yet, along with each item's product ID. Units of rejected
+// returns can be returned again.
+func returnableQuantities(tx *sql.Tx, orderID int) (map[int]int, map[int]int, error) {
+	query := `SELECT oi.id, oi.product_id, oi.quantity - COALESCE(SUM(ri.quantity) FILTER (WHERE r.status <> $2), 0)
+	FROM order_items oi
+	LEFT JOIN return_items ri ON ri.order_item_id = oi.id
+	LEFT JOIN returns r ON r.id = ri.return_id
+	WHERE oi.order_id = $1 GROUP BY oi.id`
+	rows, err := tx.Query(query, orderID, models.ReturnStatusRejected)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	returnable := map[int]int{}
+	productIDs := map[int]int{}
+	for rows.Next() {
+		var orderItemID, productID, quantity int
+		if err := rows.Scan(&orderItemID, &productID, &quantity); err != nil {
+			return nil, nil, err
+		}
+		returnable[orderItemID] = quantity
+		productIDs[orderItemID] = productID
+	}
+	return returnable, productIDs, rows.Err()
+}
+
+const returnColumns = `id, order_id, user_id, status, refund_amount, note, created_at, updated_at`
+
+func scanReturn(row rowScanner) (models.Return, error) {
+	var ret models.Return
+	err := row.Scan(&ret.ID, &ret.OrderID, &ret.UserID, &ret.Status, &ret.RefundAmount, &ret.Note, &ret.CreatedAt, &ret.UpdatedAt)
+	return ret, err
+}
+
+// lockReturn loads a return of an order without its items and locks it for
+// the rest of tx, answering 404/500 itself; ok is false when a response has
+// been written
+func lockReturn(c *gin.Context, tx *sql.Tx, orderID, returnID int) (models.Return, bool) {
+	query := `SELECT ` + returnColumns + ` FROM returns WHERE id = $1 AND order_id = $2 FOR UPDATE`
+	ret, err := scanReturn(tx.QueryRow(query, returnID, orderID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Return not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve return"})
+		}
+		return ret, false
+	}
+	return ret, true
+}
+
+func respondWithReturn(c *gin.Context, returnID int, message string) {
+	returns, err := getReturns(`WHERE id = $1`, returnID)
+	if err != nil || len(returns) == 0 {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve return"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message, "return": returns[0]})
+}
+
+// getReturns loads the returns matching where, oldest first, with their items
+// fetched in a single query
+func getReturns(where string, args ...interface{}) ([]models.Return, error) {
+	rows, err := db.DB.Query(`SELECT `+returnColumns+` FROM returns `+where+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	returns := []models.Return{}
+	positions := map[int]int{}
+	returnIDs := []int{}
+	for rows.Next() {
+		ret, err := scanReturn(rows)
+		if err != nil {
+			return nil, err
+		}
+		ret.Items = []models.ReturnItem{}
+		positions[ret.ID] = len(returns)
+		returnIDs = append(returnIDs, ret.ID)
+		returns = append(returns, ret)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(returns) == 0 {
+		return returns, nil
+	}
+
+	query := `SELECT ri.return_id, ri.id, ri.order_item_id, oi.product_id, ri.quantity, ri.reason, ri.comment, ri.accepted,
+	ri.restock, ri.restocked_at, ri.refund_amount
+	FROM return_items ri JOIN order_items oi ON oi.id = ri.order_item_id
+	WHERE ri.return_id = ANY($1) ORDER BY ri.id`
+	itemRows, err := db.DB.Query(query, pq.Array(returnIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer itemRows.Close()
+
+	for itemRows.Next() {
+		var returnID int
+		var item models.ReturnItem
+		var accepted sql.NullBool
+		var restockedAt sql.NullString
+		err := itemRows.Scan(&returnID, &item.ID, &item.OrderItemID, &item.ProductID, &item.Quantity, &item.Reason, &item.Comment,
+			&accepted, &item.Restock, &restockedAt, &item.RefundAmount)
+		if err != nil {
+			return nil, err
+		}
+		if accepted.Valid {
+			item.Accepted = &accepted.Bool
+		}
+		if restockedAt.Valid {
+			item.RestockedAt = &restockedAt.String
+		}
+		ret := &returns[positions[returnID]]
+		ret.Items = append(ret.Items, item)
+	}
+	return returns, itemRows.Err()
+}
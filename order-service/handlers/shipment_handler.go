@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"order-service/db"
+	"order-service/models"
+	"order-service/rabbitmq"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// CreateShipment records a package for a paid order. Orders can ship in
+// several packages; the order becomes Shipped once every item is in one.
+func CreateShipment(c *gin.Context) {
+	// Authentication
+	username, exists := c.Get("username")
+	if !exists || username != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	orderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var input models.ShipmentInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Lock the order so concurrent shipments cannot ship the same items twice
+	var userID int
+	var status string
+	err = tx.QueryRow(`SELECT user_id, status FROM orders WHERE id = $1 FOR UPDATE`, orderID).Scan(&userID, &status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve order"})
+		}
+		return
+	}
+	if status != models.OrderStatusPaid && status != models.OrderStatusPartiallyShipped {
+		c.JSON(http.StatusConflict, gin.H{"error": "Order cannot be shipped in status " + status})
+		return
+	}
+
+	remaining, productIDs, err := unshippedQuantities(tx, orderID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve order items"})
+		return
+	}
+
+	// Without explicit items the package holds everything still to ship
+	items := input.Items
+	if len(items) == 0 {
+		for orderItemID, quantity := range remaining {
+			if quantity > 0 {
+				items = append(items, models.ShipmentItemInput{OrderItemID: orderItemID, Quantity: quantity})
+			}
+		}
+	}
+	if len(items) == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "All items of the order have already shipped"})
+		return
+	}
+
+	for _, item := range items {
+		left, ok := remaining[item.OrderItemID]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Order item %d does not belong to order %d", item.OrderItemID, orderID)})
+			return
+		}
+		if item.Quantity <= 0 || item.Quantity > left {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Quantity for order item %d must be between 1 and %d", item.OrderItemID, left)})
+			return
+		}
+		remaining[item.OrderItemID] = left - item.Quantity
+	}
+
+	shipment := models.Shipment{
+		OrderID:        orderID,
+		Carrier:        input.Carrier,
+		TrackingNumber: input.TrackingNumber,
+		Status:         models.ShipmentStatusInTransit,
+		Items:          []models.ShipmentItem{},
+	}
+	query := `INSERT INTO shipments (order_id, carrier, tracking_number, status) VALUES ($1, $2, $3, $4) RETURNING id, shipped_at`
+	err = tx.QueryRow(query, orderID, shipment.Carrier, shipment.TrackingNumber, shipment.Status).Scan(&shipment.ID, &shipment.ShippedAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create shipment"})
+		return
+	}
+
+	for _, item := range items {
+		query = `INSERT INTO shipment_items (shipment_id, order_item_id, quantity) VALUES ($1, $2, $3)`
+		if _, err := tx.Exec(query, shipment.ID, item.OrderItemID, item.Quantity); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create shipment items"})
+			return
+		}
+		shipment.Items = append(shipment.Items, models.ShipmentItem{
+			OrderItemID: item.OrderItemID,
+			ProductID:   productIDs[item.OrderItemID],
+			Quantity:    item.Quantity,
+		})
+	}
+
+	status = models.OrderStatusShipped
+	for _, left := range remaining {
+		if left > 0 {
+			status = models.OrderStatusPartiallyShipped
+			break
+		}
+	}
+	if _, err := tx.Exec(`UPDATE orders SET status = $1 WHERE id = $2`, status, orderID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order status"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	event := models.OrderShippedEvent{
+		OrderID:        orderID,
+		UserID:         userID,
+		ShipmentID:     shipment.ID,
+		Status:         status,
+		Carrier:        shipment.Carrier,
+		TrackingNumber: shipment.TrackingNumber,
+		Items:          []models.OrderItemInfo{},
+	}
+	for _, item := range shipment.Items {
+		event.Items = append(event.Items, models.OrderItemInfo{ProductID: item.ProductID, Quantity: item.Quantity})
+	}
+	rabbitmq.EmitOrderShipped(event)
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Shipment created successfully", "order_status": status, "shipment": shipment})
+}
+
+// UpdateShipmentStatus records carrier progress. When the last package of a
+// fully shipped order is delivered, the order becomes Delivered.
+func UpdateShipmentStatus(c *gin.Context) {
+	// Authentication
+	username, exists := c.Get("username")
+	if !exists || username != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	orderID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+	shipmentID, err := strconv.Atoi(c.Param("shipment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid shipment ID"})
+		return
+	}
+
+	var input models.ShipmentStatusInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Status != models.ShipmentStatusInTransit && input.Status != models.ShipmentStatusDelivered {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Status must be in_transit or delivered"})
+		return
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	var orderStatus string
+	err = tx.QueryRow(`SELECT status FROM orders WHERE id = $1 FOR UPDATE`, orderID).Scan(&orderStatus)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve order"})
+		}
+		return
+	}
+
+	query := `UPDATE shipments SET status = $1,
+	delivered_at = CASE WHEN $1 = 'delivered' THEN COALESCE(delivered_at, CURRENT_TIMESTAMP) END
+	WHERE id = $2 AND order_id = $3`
+	result, err := tx.Exec(query, input.Status, shipmentID, orderID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update shipment"})
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shipment not found"})
+		return
+	}
+
+	// Derive the order status from its shipments
+	var undelivered int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM shipments WHERE order_id = $1 AND status <> $2`,
+		orderID, models.ShipmentStatusDelivered).Scan(&undelivered)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order status"})
+		return
+	}
+	switch {
+	case orderStatus == models.OrderStatusShipped && undelivered == 0:
+		orderStatus = models.OrderStatusDelivered
+	case orderStatus == models.OrderStatusDelivered && undelivered > 0:
+		orderStatus = models.OrderStatusShipped
+	}
+	if _, err := tx.Exec(`UPDATE orders SET status = $1 WHERE id = $2`, orderStatus, orderID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order status"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Shipment updated successfully", "order_status": orderStatus})
+}
+
+// unshippedQuantities returns, per order item, how many units are not in a
+// shipment yet, along with each item's product ID
+func unshippedQuantities(tx *sql.Tx, orderID int) (map[int]int, map[int]int, error) {
+	query := `SELECT oi.id, oi.product_id, oi.quantity - COALESCE(SUM(si.quantity), 0)
+	FROM order_items oi LEFT JOIN shipment_items si ON si.order_item_id = oi.id
+	WHERE oi.order_id = $1 GROUP BY oi.id`
+	rows, err := tx.Query(query, orderID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	remaining := map[int]int{}
+	productIDs := map[int]int{}
+	for rows.Next() {
+		var orderItemID, productID, quantity int
+		if err := rows.Scan(&orderItemID, &productID, &quantity); err != nil {
+			return nil, nil, err
+		}
+		remaining[orderItemID] = quantity
+		productIDs[orderItemID] = productID
+	}
+	return remaining, productIDs, rows.Err()
+}
+
+func getOrderShipments(orderID int) ([]models.Shipment, error) {
+	shipments, err := getShipmentsForOrders([]int{orderID})
+	if err != nil {
+		return nil, err
+	}
+	if shipments[orderID] == nil {
+		return []models.Shipment{}, nil
+	}
+	return shipments[orderID], nil
+}
+
+// getShipmentsForOrders loads the shipments of several orders in one query,
+// keyed by order ID
+func getShipmentsForOrders(orderIDs []int) (map[int][]models.Shipment, error) {
+	query := `SELECT s.id, s.order_id, s.carrier, s.tracking_number, s.status, s.shipped_at, s.delivered_at,
+	si.order_item_id, oi.product_id, si.quantity
+	FROM shipments s
+	JOIN shipment_items si ON si.shipment_id = s.id
+	JOIN order_items oi ON oi.id = si.order_item_id
+	WHERE s.order_id = ANY($1) ORDER BY s.id, si.order_item_id`
+	rows, err := db.DB.Query(query, pq.Array(orderIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shipments := map[int][]models.Shipment{}
+	for rows.Next() {
+		var shipment models.Shipment
+		var deliveredAt sql.NullString
+		var item models.ShipmentItem
+		err := rows.Scan(&shipment.ID, &shipment.OrderID, &shipment.Carrier, &shipment.TrackingNumber, &shipment.Status,
+			&shipment.ShippedAt, &deliveredAt, &item.OrderItemID, &item.ProductID, &item.Quantity)
+		if err != nil {
+			return nil, err
+		}
+
+		// Rows are grouped by shipment, so only the order's last one can be
+		// extended
+		orderShipments := shipments[shipment.OrderID]
+		if n := len(orderShipments); n > 0 && orderShipments[n-1].ID == shipment.ID {
+			orderShipments[n-1].Items = append(orderShipments[n-1].Items, item)
+			continue
+		}
+		if deliveredAt.Valid {
+			shipment.DeliveredAt = &deliveredAt.String
+		}
+		shipment.Items = []models.ShipmentItem{item}
+		shipments[shipment.OrderID] = append(orderShipments, shipment)
+	}
+	return shipments, rows.Err()
+}
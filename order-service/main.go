@@ -1,10 +1,13 @@
 package main
 
 import (
+	"order-service/cart"
 	"order-service/db"
 	"order-service/handlers"
+	"order-service/payments"
 	"order-service/rabbitmq"
 	"order-service/utils"
+	"shared/idempotency"
 
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus"
@@ -16,10 +19,19 @@ func main() {
 	db.Init()
 	defer db.DB.Close()
 
+	// Initialize idempotency keys and purge expired ones
+	idempotency.Init(db.DB)
+
 	// Initialize RabbitMQ
 	rabbitmq.Init()
 	defer rabbitmq.Close()
 
+	// Initialize Redis for shopping carts
+	cart.Init()
+
+	// Initialize payment providers
+	payments.Init()
+
 	// Initialize HTTP Client
 	utils.InitHTTPClient()
 
@@ -28,6 +40,7 @@ func main() {
 
 	// Start listening for events
 	rabbitmq.ListenForEvents()
+	rabbitmq.ListenForOrderStockRejected(handlers.RejectOrderStock)
 
 	// Set up router
 	r := gin.Default()
@@ -51,12 +64,53 @@ func main() {
 		c.Next()
 	})
 
+	// Cart routes work for guests (X-Cart-ID header) and signed-in users
+	carts := r.Group("/cart", handlers.OptionalAuthenticate)
+	{
+		carts.GET("", handlers.GetCart)
+		carts.DELETE("", handlers.ClearCart)
+		carts.POST("/items", handlers.AddCartItem)
+		carts.PUT("/items/:product_id", handlers.UpdateCartItem)
+		carts.DELETE("/items/:product_id", handlers.RemoveCartItem)
+	}
+
+	// Payment provider callbacks are authenticated by signature, not JWT
+	r.POST("/payments/webhooks/:provider", handlers.PaymentWebhook)
+
 	// Protected routes
 	authorized := r.Group("/", handlers.Authenticate)
 	{
-		authorized.POST("/orders", handlers.PlaceOrder)
+		authorized.POST("/orders", idempotency.Middleware, handlers.PlaceOrder)
+		authorized.POST("/orders/quote", handlers.QuoteOrder)
 		authorized.GET("/orders", handlers.GetAllOrders)
 		authorized.GET("/orders/:id", handlers.GetOrderByID)
+		authorized.POST("/orders/:id/cancel", handlers.CancelOrder)
+		authorized.POST("/orders/:id/pay", idempotency.Middleware, handlers.PayOrder)
+		authorized.GET("/orders/:id/payments", handlers.GetOrderPayments)
+		authorized.POST("/orders/:id/payments/capture", handlers.CapturePayment)
+		authorized.POST("/orders/:id/payments/refund", handlers.RefundPayment)
+		authorized.POST("/orders/:id/shipments", handlers.CreateShipment)
+		authorized.PUT("/orders/:id/shipments/:shipment_id", handlers.UpdateShipmentStatus)
+		authorized.POST("/orders/:id/returns", handlers.RequestReturn)
+		authorized.GET("/orders/:id/returns", handlers.GetOrderReturns)
+		authorized.PUT("/orders/:id/returns/:return_id", handlers.UpdateReturnStatus)
+		authorized.POST("/orders/:id/returns/:return_id/inspect", handlers.InspectReturn)
+		authorized.POST("/orders/:id/returns/:return_id/refund", handlers.RefundReturn)
+		authorized.GET("/admin/returns", handlers.GetReturns)
+		authorized.GET("/admin/orders", handlers.GetAdminOrders)
+		authorized.GET("/admin/orders/export", handlers.ExportAdminOrders)
+		authorized.GET("/admin/coupons", handlers.GetCoupons)
+		authorized.POST("/admin/coupons", handlers.CreateCoupon)
+		authorized.PUT("/admin/coupons/:id", handlers.UpdateCoupon)
+		authorized.DELETE("/admin/coupons/:id", handlers.DeleteCoupon)
+		authorized.GET("/admin/tax-rules", handlers.GetTaxRules)
+		authorized.POST("/admin/tax-rules", handlers.CreateTaxRule)
+		authorized.DELETE("/admin/tax-rules/:id", handlers.DeleteTaxRule)
+		authorized.GET("/admin/shipping-rates", handlers.GetShippingRates)
+		authorized.POST("/admin/shipping-rates", handlers.CreateShippingRate)
+		authorized.DELETE("/admin/shipping-rates/:id", handlers.DeleteShippingRate)
+		authorized.POST("/cart/merge", handlers.MergeCart)
+		authorized.POST("/cart/checkout", idempotency.Middleware, handlers.Checkout)
 	}
 
 	// Start server
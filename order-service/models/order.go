@@ -1,12 +1,87 @@
 package models
 
+// Order statuses
+const (
+	OrderStatusPlaced           = "Placed"
+	OrderStatusPaid             = "Paid"
+	OrderStatusPaymentFailed    = "Payment Failed"
+	OrderStatusPartiallyShipped = "Partially Shipped"
+	OrderStatusShipped          = "Shipped"
+	OrderStatusDelivered        = "Delivered"
+	OrderStatusCancelled        = "Cancelled"
+)
+
+// Shipment statuses
+const (
+	ShipmentStatusInTransit = "in_transit"
+	ShipmentStatusDelivered = "delivered"
+)
+
 type Order struct {
-	ID        int         `json:"id"`
-	UserID    int         `json:"user_id"`
-	Status    string      `json:"status"`
-	Total     float64     `json:"total"`
-	CreatedAt string      `json:"created_at"`
-	Items     []OrderItem `json:"items"`
+	ID              int              `json:"id"`
+	UserID          int              `json:"user_id"`
+	Status          string           `json:"status"`
+	Subtotal        float64          `json:"subtotal"`
+	DiscountTotal   float64          `json:"discount_total"`
+	TaxTotal        float64          `json:"tax_total"`
+	ShippingTotal   float64          `json:"shipping_total"`
+	Total           float64          `json:"total"`
+	CouponCode      *string          `json:"coupon_code"`
+	FreeShipping    bool             `json:"free_shipping"`
+	CreatedAt       string           `json:"created_at"`
+	CancelledAt     *string          `json:"cancelled_at,omitempty"`
+	CancelReason    *string          `json:"cancel_reason,omitempty"`
+	ShippingAddress *ShippingAddress `json:"shipping_address"`
+	Items           []OrderItem      `json:"items"`
+	Shipments       []Shipment       `json:"shipments"`
+}
+
+// ShippingAddress is the copy of a user_service address taken when the order
+// is placed, so later edits to the address book do not change the order
+type ShippingAddress struct {
+	AddressID  int    `json:"address_id"`
+	Label      string `json:"label"`
+	FullName   string `json:"full_name"`
+	Line1      string `json:"line1"`
+	Line2      string `json:"line2"`
+	City       string `json:"city"`
+	Region     string `json:"region"`
+	PostalCode string `json:"postal_code"`
+	Country    string `json:"country"`
+	Phone      string `json:"phone"`
+}
+
+type Shipment struct {
+	ID             int            `json:"id"`
+	OrderID        int            `json:"order_id"`
+	Carrier        string         `json:"carrier"`
+	TrackingNumber string         `json:"tracking_number"`
+	Status         string         `json:"status"`
+	ShippedAt      string         `json:"shipped_at"`
+	DeliveredAt    *string        `json:"delivered_at"`
+	Items          []ShipmentItem `json:"items"`
+}
+
+type ShipmentItem struct {
+	OrderItemID int `json:"order_item_id"`
+	ProductID   int `json:"product_id"`
+	Quantity    int `json:"quantity"`
+}
+
+// ShipmentInput creates a package; without items everything not yet shipped goes in it
+type ShipmentInput struct {
+	Carrier        string              `json:"carrier" binding:"required"`
+	TrackingNumber string              `json:"tracking_number" binding:"required"`
+	Items          []ShipmentItemInput `json:"items"`
+}
+
+type ShipmentItemInput struct {
+	OrderItemID int `json:"order_item_id" binding:"required"`
+	Quantity    int `json:"quantity" binding:"required"`
+}
+
+type ShipmentStatusInput struct {
+	Status string `json:"status" binding:"required"`
 }
 
 type OrderItem struct {
@@ -15,10 +90,22 @@ type OrderItem struct {
 	ProductID int     `json:"product_id"`
 	Quantity  int     `json:"quantity"`
 	Price     float64 `json:"price"`
+	// Discount and Tax apply to the whole line, not per unit
+	Discount float64 `json:"discount"`
+	Tax      float64 `json:"tax"`
 }
 
 type OrderInput struct {
-	Items []OrderItemInput `json:"items"`
+	Items      []OrderItemInput `json:"items"`
+	CouponCode string           `json:"coupon_code"`
+	// ShippingAddressID picks an address from the user's address book;
+	// the default address is used when it is omitted
+	ShippingAddressID *int `json:"shipping_address_id"`
+}
+
+type CheckoutInput struct {
+	ShippingAddressID *int   `json:"shipping_address_id"`
+	CouponCode        string `json:"coupon_code"`
 }
 
 type OrderItemInput struct {
@@ -37,8 +124,51 @@ type OrderItemInfo struct {
 	Quantity  int `json:"quantity"`
 }
 
+type CancelOrderInput struct {
+	Reason string `json:"reason"`
+}
+
+// OrderCancelledEvent carries the order lines so product-service can return
+// their stock
+type OrderCancelledEvent struct {
+	OrderID int    `json:"order_id"`
+	UserID  int    `json:"user_id"`
+	Reason  string `json:"reason"`
+	// CancelledBy is the actor who cancelled, e.g. "user:42" or "admin"
+	CancelledBy    string          `json:"cancelled_by"`
+	RefundedAmount float64         `json:"refunded_amount"`
+	Items          []OrderItemInfo `json:"items"`
+}
+
+// OrderStockRejectedEvent lists the items of an order product-service could
+// not take the stock for
+type OrderStockRejectedEvent struct {
+	OrderID int                 `json:"order_id"`
+	UserID  int                 `json:"user_id"`
+	Items   []RejectedOrderItem `json:"items"`
+}
+
+// Reasons product-service gives for a rejected item
+const (
+	RejectReasonInsufficientStock = "insufficient_stock"
+	RejectReasonProductNotFound   = "product_not_found"
+	RejectReasonFailed            = "failed"
+)
+
+type RejectedOrderItem struct {
+	ProductID int    `json:"product_id"`
+	Quantity  int    `json:"quantity"`
+	Reason    string `json:"reason"`
+}
+
 type OrderShippedEvent struct {
-	OrderID int `json:"order_id"`
+	OrderID        int             `json:"order_id"`
+	UserID         int             `json:"user_id"`
+	ShipmentID     int             `json:"shipment_id"`
+	Status         string          `json:"status"`
+	Carrier        string          `json:"carrier"`
+	TrackingNumber string          `json:"tracking_number"`
+	Items          []OrderItemInfo `json:"items"`
 }
 
 type Product struct {
@@ -46,7 +176,23 @@ type Product struct {
 	Name        string  `json:"name"`
 	Description string  `json:"description"`
 	Price       float64 `json:"price"`
+	Category    string  `json:"category"`
+	TaxClass    string  `json:"tax_class"`
+	WeightGrams int     `json:"weight_grams"`
 	Inventory   int     `json:"inventory"`
+	Version     int     `json:"version"`
+}
+
+// ProductUpdatedEvent carries only the fields that changed plus the new version
+type ProductUpdatedEvent struct {
+	ID          int      `json:"id"`
+	Version     int      `json:"version"`
+	Name        *string  `json:"name"`
+	Description *string  `json:"description"`
+	Price       *float64 `json:"price"`
+	Category    *string  `json:"category"`
+	TaxClass    *string  `json:"tax_class"`
+	WeightGrams *int     `json:"weight_grams"`
 }
 
 type User struct {
@@ -58,3 +204,246 @@ type InventoryUpdateEvent struct {
 	ProductID    int `json:"product_id"`
 	NewInventory int `json:"new_inventory"`
 }
+
+type Cart struct {
+	CartID       string     `json:"cart_id,omitempty"`
+	Items        []CartItem `json:"items"`
+	ItemCount    int        `json:"item_count"`
+	Subtotal     float64    `json:"subtotal"`
+	Checkoutable bool       `json:"checkoutable"`
+}
+
+// CartItem is a cart line annotated with the live catalog price and availability
+type CartItem struct {
+	ProductID         int     `json:"product_id"`
+	Name              string  `json:"name"`
+	Quantity          int     `json:"quantity"`
+	UnitPrice         float64 `json:"unit_price"`
+	LineTotal         float64 `json:"line_total"`
+	Available         bool    `json:"available"`
+	AvailableQuantity int     `json:"available_quantity"`
+	Issue             string  `json:"issue,omitempty"`
+}
+
+type CartItemInput struct {
+	ProductID int `json:"product_id" binding:"required"`
+	Quantity  int `json:"quantity" binding:"required"`
+}
+
+type CartQuantityInput struct {
+	Quantity int `json:"quantity"`
+}
+
+type CartMergeInput struct {
+	CartID string `json:"cart_id" binding:"required"`
+}
+
+type Payment struct {
+	ID             int     `json:"id"`
+	OrderID        int     `json:"order_id"`
+	Provider       string  `json:"provider"`
+	ProviderRef    string  `json:"provider_ref"`
+	Status         string  `json:"status"`
+	Amount         float64 `json:"amount"`
+	CapturedAmount float64 `json:"captured_amount"`
+	RefundedAmount float64 `json:"refunded_amount"`
+	FailureReason  string  `json:"failure_reason,omitempty"`
+	CreatedAt      string  `json:"created_at"`
+	UpdatedAt      string  `json:"updated_at"`
+}
+
+type PaymentInput struct {
+	PaymentMethod string `json:"payment_method" binding:"required"`
+	// Capture defaults to true; set it to false to only authorize
+	Capture *bool `json:"capture"`
+}
+
+type RefundInput struct {
+	Amount float64 `json:"amount"`
+}
+
+type PaymentEvent struct {
+	OrderID   int     `json:"order_id"`
+	UserID    int     `json:"user_id"`
+	PaymentID int     `json:"payment_id"`
+	Amount    float64 `json:"amount"`
+	Reason    string  `json:"reason,omitempty"`
+}
+
+// Coupon types
+const (
+	CouponTypePercentage   = "percentage"
+	CouponTypeFixedAmount  = "fixed_amount"
+	CouponTypeFreeShipping = "free_shipping"
+)
+
+// Coupon is a discount code. Without product IDs or categories it applies to
+// every line; otherwise only to lines matching either restriction.
+type Coupon struct {
+	ID             int      `json:"id"`
+	Code           string   `json:"code"`
+	Type           string   `json:"type"`
+	Value          float64  `json:"value"`
+	MinOrderValue  float64  `json:"min_order_value"`
+	MaxUses        *int     `json:"max_uses"`
+	MaxUsesPerUser *int     `json:"max_uses_per_user"`
+	StartsAt       *string  `json:"starts_at"`
+	ExpiresAt      *string  `json:"expires_at"`
+	ProductIDs     []int    `json:"product_ids"`
+	Categories     []string `json:"categories"`
+	Active         bool     `json:"active"`
+	TimesRedeemed  int      `json:"times_redeemed"`
+	CreatedAt      string   `json:"created_at"`
+}
+
+type CouponInput struct {
+	Code           string   `json:"code" binding:"required"`
+	Type           string   `json:"type" binding:"required"`
+	Value          float64  `json:"value"`
+	MinOrderValue  float64  `json:"min_order_value"`
+	MaxUses        *int     `json:"max_uses"`
+	MaxUsesPerUser *int     `json:"max_uses_per_user"`
+	StartsAt       *string  `json:"starts_at"`
+	ExpiresAt      *string  `json:"expires_at"`
+	ProductIDs     []int    `json:"product_ids"`
+	Categories     []string `json:"categories"`
+	Active         *bool    `json:"active"`
+}
+
+// PriceQuote is the price breakdown of an order, as stored or as quoted
+type PriceQuote struct {
+	Items           []QuoteItem      `json:"items"`
+	Subtotal        float64          `json:"subtotal"`
+	DiscountTotal   float64          `json:"discount_total"`
+	TaxTotal        float64          `json:"tax_total"`
+	ShippingTotal   float64          `json:"shipping_total"`
+	Total           float64          `json:"total"`
+	CouponCode      *string          `json:"coupon_code"`
+	FreeShipping    bool             `json:"free_shipping"`
+	WeightGrams     int              `json:"weight_grams"`
+	ShippingAddress *ShippingAddress `json:"shipping_address"`
+}
+
+type QuoteItem struct {
+	ProductID int     `json:"product_id"`
+	Quantity  int     `json:"quantity"`
+	UnitPrice float64 `json:"unit_price"`
+	Discount  float64 `json:"discount"`
+	Tax       float64 `json:"tax"`
+	LineTotal float64 `json:"line_total"`
+}
+
+// TaxRule is the rate for a tax class in a country, or in one region of it
+// when Region is set. Region rules win over country rules.
+type TaxRule struct {
+	ID       int     `json:"id"`
+	Country  string  `json:"country" binding:"required,len=2"`
+	Region   string  `json:"region"`
+	TaxClass string  `json:"tax_class" binding:"required"`
+	Rate     float64 `json:"rate"`
+}
+
+// ShippingRate is the price for shipments up to MaxWeightGrams to Country.
+// An empty Country applies everywhere and a nil MaxWeightGrams is a flat
+// rate for any weight.
+type ShippingRate struct {
+	ID             int     `json:"id"`
+	Country        string  `json:"country"`
+	MaxWeightGrams *int    `json:"max_weight_grams"`
+	Rate           float64 `json:"rate"`
+}
+
+// Return statuses. A return is requested by the customer, approved or
+// rejected by an admin, received at the warehouse, inspected, and finally
+// refunded.
+const (
+	ReturnStatusRequested = "requested"
+	ReturnStatusApproved  = "approved"
+	ReturnStatusRejected  = "rejected"
+	ReturnStatusReceived  = "received"
+	ReturnStatusInspected = "inspected"
+	ReturnStatusRefunding = "refunding"
+	ReturnStatusRefunded  = "refunded"
+)
+
+// ReturnReasons are the accepted reasons for returning an order line
+var ReturnReasons = map[string]bool{
+	"damaged":          true,
+	"defective":        true,
+	"wrong_item":       true,
+	"not_as_described": true,
+	"no_longer_needed": true,
+	"other":            true,
+}
+
+type Return struct {
+	ID           int          `json:"id"`
+	OrderID      int          `json:"order_id"`
+	UserID       int          `json:"user_id"`
+	Status       string       `json:"status"`
+	RefundAmount float64      `json:"refund_amount"`
+	Note         string       `json:"note"`
+	CreatedAt    string       `json:"created_at"`
+	UpdatedAt    string       `json:"updated_at"`
+	Items        []ReturnItem `json:"items"`
+}
+
+// ReturnItem is one order line being returned. Accepted is unset until the
+// item has been inspected.
+type ReturnItem struct {
+	ID           int     `json:"id"`
+	OrderItemID  int     `json:"order_item_id"`
+	ProductID    int     `json:"product_id"`
+	Quantity     int     `json:"quantity"`
+	Reason       string  `json:"reason"`
+	Comment      string  `json:"comment"`
+	Accepted     *bool   `json:"accepted"`
+	Restock      bool    `json:"restock"`
+	RestockedAt  *string `json:"restocked_at"`
+	RefundAmount float64 `json:"refund_amount"`
+}
+
+type ReturnInput struct {
+	Items []ReturnItemInput `json:"items" binding:"required"`
+}
+
+type ReturnItemInput struct {
+	OrderItemID int    `json:"order_item_id" binding:"required"`
+	Quantity    int    `json:"quantity" binding:"required"`
+	Reason      string `json:"reason" binding:"required"`
+	Comment     string `json:"comment"`
+}
+
+type ReturnStatusInput struct {
+	Status string `json:"status" binding:"required"`
+	Note   string `json:"note"`
+}
+
+// InspectReturnInput records the outcome of inspecting every returned item.
+// Only accepted items are refunded, and only accepted items can be restocked.
+type InspectReturnInput struct {
+	Items []InspectReturnItemInput `json:"items" binding:"required"`
+	Note  string                   `json:"note"`
+}
+
+type InspectReturnItemInput struct {
+	OrderItemID int  `json:"order_item_id" binding:"required"`
+	Accepted    bool `json:"accepted"`
+	Restock     bool `json:"restock"`
+}
+
+// ReturnInspectedEvent lists the products to put back into stock
+type ReturnInspectedEvent struct {
+	ReturnID int             `json:"return_id"`
+	OrderID  int             `json:"order_id"`
+	UserID   int             `json:"user_id"`
+	Items    []OrderItemInfo `json:"items"`
+}
+
+// ReturnRestockedEvent confirms the products product-service put back into
+// stock for a return
+type ReturnRestockedEvent struct {
+	ReturnID int             `json:"return_id"`
+	OrderID  int             `json:"order_id"`
+	Items    []OrderItemInfo `json:"items"`
+}
@@ -0,0 +1,164 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// FakeSignatureHeader carries the hex HMAC-SHA256 of a fake webhook body
+const FakeSignatureHeader = "X-Fake-Signature"
+
+// Payment methods with special behaviour in the fake provider; any other
+// token is approved.
+const (
+	FakeMethodDeclined          = "tok_declined"
+	FakeMethodInsufficientFunds = "tok_insufficient_funds"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrUnknownPayment   = errors.New("unknown payment reference")
+)
+
+// FakeProvider is an in-process provider for local development and testing
+type FakeProvider struct {
+	secret []byte
+
+	mu       sync.Mutex
+	payments map[string]*fakePayment
+}
+
+type fakePayment struct {
+	authorized float64
+	captured   float64
+	refunded   float64
+	voided     bool
+}
+
+func NewFakeProvider(webhookSecret string) *FakeProvider {
+	return &FakeProvider{secret: []byte(webhookSecret), payments: map[string]*fakePayment{}}
+}
+
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *FakeProvider) Authorize(ctx context.Context, req AuthorizeRequest) (Result, error) {
+	ref := "fake_" + randomHex(12)
+	switch req.PaymentMethod {
+	case FakeMethodDeclined:
+		return Result{ProviderRef: ref, Status: StatusFailed, FailureReason: "card_declined"}, nil
+	case FakeMethodInsufficientFunds:
+		return Result{ProviderRef: ref, Status: StatusFailed, FailureReason: "insufficient_funds"}, nil
+	}
+
+	p.mu.Lock()
+	p.payments[ref] = &fakePayment{authorized: req.Amount}
+	p.mu.Unlock()
+	return Result{ProviderRef: ref, Status: StatusAuthorized}, nil
+}
+
+func (p *FakeProvider) Capture(ctx context.Context, providerRef string, amount float64) (Result, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	payment, ok := p.payments[providerRef]
+	if !ok {
+		return Result{}, ErrUnknownPayment
+	}
+	if payment.voided {
+		return Result{ProviderRef: providerRef, Status: StatusFailed, FailureReason: "authorization_voided"}, nil
+	}
+	if payment.captured+amount > payment.authorized+0.005 {
+		return Result{ProviderRef: providerRef, Status: StatusFailed, FailureReason: "amount_exceeds_authorization"}, nil
+	}
+	payment.captured += amount
+	return Result{ProviderRef: providerRef, Status: StatusCaptured}, nil
+}
+
+func (p *FakeProvider) Refund(ctx context.Context, providerRef string, amount float64) (Result, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	payment, ok := p.payments[providerRef]
+	if !ok {
+		return Result{}, ErrUnknownPayment
+	}
+	if payment.refunded+amount > payment.captured+0.005 {
+		return Result{ProviderRef: providerRef, Status: StatusFailed, FailureReason: "amount_exceeds_capture"}, nil
+	}
+	payment.refunded += amount
+	status := StatusPartiallyRefunded
+	if payment.refunded >= payment.captured-0.005 {
+		status = StatusRefunded
+	}
+	return Result{ProviderRef: providerRef, Status: status}, nil
+}
+
+func (p *FakeProvider) Void(ctx context.Context, providerRef string) (Result, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	payment, ok := p.payments[providerRef]
+	if !ok {
+		return Result{}, ErrUnknownPayment
+	}
+	if payment.captured > 0 {
+		return Result{ProviderRef: providerRef, Status: StatusFailed, FailureReason: "already_captured"}, nil
+	}
+	payment.voided = true
+	return Result{ProviderRef: providerRef, Status: StatusVoided}, nil
+}
+
+// ParseWebhook expects a JSON body
+// {"id", "type", "payment_ref", "amount", "failure_reason"} signed with the
+// shared secret in the X-Fake-Signature header.
+func (p *FakeProvider) ParseWebhook(header http.Header, body []byte) (WebhookEvent, error) {
+	signature, err := hex.DecodeString(header.Get(FakeSignatureHeader))
+	if err != nil || !hmac.Equal(signature, p.Sign(body)) {
+		return WebhookEvent{}, ErrInvalidSignature
+	}
+
+	var payload struct {
+		ID            string  `json:"id"`
+		Type          string  `json:"type"`
+		PaymentRef    string  `json:"payment_ref"`
+		Amount        float64 `json:"amount"`
+		FailureReason string  `json:"failure_reason"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return WebhookEvent{}, err
+	}
+	if payload.ID == "" || payload.PaymentRef == "" {
+		return WebhookEvent{}, fmt.Errorf("webhook is missing id or payment_ref")
+	}
+
+	return WebhookEvent{
+		EventID:       payload.ID,
+		Type:          payload.Type,
+		ProviderRef:   payload.PaymentRef,
+		Amount:        payload.Amount,
+		FailureReason: payload.FailureReason,
+	}, nil
+}
+
+// Sign returns the HMAC-SHA256 of body with the webhook secret
+func (p *FakeProvider) Sign(body []byte) []byte {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,123 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+)
+
+// Payment statuses stored in the payments table
+const (
+	// StatusPending marks a payment claimed for an order while the provider
+	// authorizes it
+	StatusPending           = "pending"
+	StatusAuthorized        = "authorized"
+	StatusCaptured          = "captured"
+	StatusFailed            = "failed"
+	StatusRefunded          = "refunded"
+	StatusPartiallyRefunded = "partially_refunded"
+	// StatusRefunding marks a payment while a refund is with the provider
+	StatusRefunding = "refunding"
+	// StatusVoiding marks an authorized payment while its authorization is
+	// being released, and StatusVoided one that was released uncaptured
+	StatusVoiding = "voiding"
+	StatusVoided  = "voided"
+)
+
+// Webhook event types understood by HandleWebhook
+const (
+	EventAuthorized = "payment.authorized"
+	EventCaptured   = "payment.captured"
+	EventFailed     = "payment.failed"
+	EventRefunded   = "payment.refunded"
+	EventVoided     = "payment.voided"
+)
+
+var ErrUnknownProvider = errors.New("unknown payment provider")
+
+// ErrRefundInProgress is returned when the payment is already being refunded,
+// or no longer has the amount left to refund
+var ErrRefundInProgress = errors.New("payment is already being refunded")
+
+// ErrPaymentExists is returned when the order already has a payment that did
+// not fail, including one that is still pending
+var ErrPaymentExists = errors.New("order already has a payment")
+
+// ErrVoidInProgress is returned when the payment is already being voided, or
+// is no longer only authorized
+var ErrVoidInProgress = errors.New("payment is already being voided")
+
+// PaymentProvider is implemented by every payment gateway adapter
+type PaymentProvider interface {
+	Name() string
+	Authorize(ctx context.Context, req AuthorizeRequest) (Result, error)
+	Capture(ctx context.Context, providerRef string, amount float64) (Result, error)
+	Refund(ctx context.Context, providerRef string, amount float64) (Result, error)
+	// Void releases an authorization that was not captured
+	Void(ctx context.Context, providerRef string) (Result, error)
+	// ParseWebhook verifies the authenticity of a provider callback and decodes it
+	ParseWebhook(header http.Header, body []byte) (WebhookEvent, error)
+}
+
+type AuthorizeRequest struct {
+	OrderID       int
+	Amount        float64
+	Currency      string
+	PaymentMethod string
+}
+
+// Result is the outcome of a provider operation. A declined operation is not
+// an error: Status is StatusFailed and FailureReason explains why.
+type Result struct {
+	ProviderRef   string
+	Status        string
+	FailureReason string
+}
+
+type WebhookEvent struct {
+	EventID       string
+	Type          string
+	ProviderRef   string
+	Amount        float64
+	FailureReason string
+}
+
+var providers = map[string]PaymentProvider{}
+var defaultProvider string
+
+// Init registers the provider selected with PAYMENT_PROVIDER. The fake
+// provider approves any payment and accepts webhooks signed with its secret,
+// so it is only registered when chosen explicitly and never without a secret.
+func Init() {
+	defaultProvider = os.Getenv("PAYMENT_PROVIDER")
+	if defaultProvider == "fake" {
+		secret := os.Getenv("FAKE_PAYMENT_WEBHOOK_SECRET")
+		if secret == "" {
+			log.Fatal("FAKE_PAYMENT_WEBHOOK_SECRET must be set to use the fake payment provider")
+		}
+		Register(NewFakeProvider(secret))
+	}
+
+	if _, ok := providers[defaultProvider]; !ok {
+		log.Fatalf("Unknown PAYMENT_PROVIDER %q", defaultProvider)
+	}
+	log.Printf("Using %s payment provider", defaultProvider)
+}
+
+func Register(provider PaymentProvider) {
+	providers[provider.Name()] = provider
+}
+
+func Provider(name string) (PaymentProvider, error) {
+	provider, ok := providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return provider, nil
+}
+
+func Default() PaymentProvider {
+	return providers[defaultProvider]
+}
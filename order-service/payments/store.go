@@ -0,0 +1,265 @@
+package payments
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"order-service/db"
+	"order-service/models"
+)
+
+const paymentColumns = `id, order_id, provider, provider_ref, status, amount, captured_amount, refunded_amount, failure_reason, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPayment(row rowScanner) (models.Payment, error) {
+	var payment models.Payment
+	err := row.Scan(&payment.ID, &payment.OrderID, &payment.Provider, &payment.ProviderRef, &payment.Status, &payment.Amount,
+		&payment.CapturedAmount, &payment.RefundedAmount, &payment.FailureReason, &payment.CreatedAt, &payment.UpdatedAt)
+	return payment, err
+}
+
+// A pending payment older than this belongs to a request that died between
+// claiming the payment and recording the provider's answer
+const pendingTimeout = "5 minutes"
+
+// Pay authorizes the amount for an order with the default provider and, when
+// capture is set, captures it right away. The payment is claimed as pending
+// first, and the order's only active payment is enforced by a unique index, so
+// a concurrent request fails with ErrPaymentExists while no lock is held
+// during the provider call. Declined payments and provider errors are stored
+// as failed, so the order can be paid again.
+func Pay(ctx context.Context, orderID int, amount float64, paymentMethod string, capture bool) (models.Payment, error) {
+	provider := Default()
+
+	_, err := db.DB.Exec(`UPDATE payments SET status = $1, failure_reason = 'abandoned', updated_at = CURRENT_TIMESTAMP
+	WHERE order_id = $2 AND status = $3 AND created_at < CURRENT_TIMESTAMP - INTERVAL '`+pendingTimeout+`'`,
+		StatusFailed, orderID, StatusPending)
+	if err != nil {
+		return models.Payment{}, err
+	}
+
+	// The provider reference is not known yet; a placeholder keeps it unique
+	query := `INSERT INTO payments (order_id, provider, provider_ref, status, amount) VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (order_id) WHERE status <> 'failed' DO NOTHING RETURNING ` + paymentColumns
+	payment, err := scanPayment(db.DB.QueryRow(query, orderID, provider.Name(), "pending_"+randomHex(12), StatusPending, amount))
+	if err == sql.ErrNoRows {
+		return payment, ErrPaymentExists
+	}
+	if err != nil {
+		return payment, err
+	}
+
+	result, err := provider.Authorize(ctx, AuthorizeRequest{
+		OrderID:       orderID,
+		Amount:        amount,
+		Currency:      "USD",
+		PaymentMethod: paymentMethod,
+	})
+	if err != nil {
+		payment.Status = StatusFailed
+		payment.FailureReason = "provider_error"
+		if err := save(payment); err != nil {
+			log.Printf("Failed to release pending payment %d: %v", payment.ID, err)
+		}
+		return payment, err
+	}
+
+	payment.ProviderRef = result.ProviderRef
+	payment.Status = result.Status
+	payment.FailureReason = result.FailureReason
+	_, err = db.DB.Exec(`UPDATE payments SET provider_ref = $1, status = $2, failure_reason = $3, updated_at = CURRENT_TIMESTAMP WHERE id = $4`,
+		payment.ProviderRef, payment.Status, payment.FailureReason, payment.ID)
+	if err != nil {
+		return payment, err
+	}
+
+	if capture && payment.Status == StatusAuthorized {
+		return Capture(ctx, payment)
+	}
+	return payment, nil
+}
+
+// Capture captures the full authorized amount of a payment
+func Capture(ctx context.Context, payment models.Payment) (models.Payment, error) {
+	provider, err := Provider(payment.Provider)
+	if err != nil {
+		return payment, err
+	}
+
+	result, err := provider.Capture(ctx, payment.ProviderRef, payment.Amount)
+	if err != nil {
+		return payment, err
+	}
+
+	payment.Status = result.Status
+	payment.FailureReason = result.FailureReason
+	if result.Status == StatusCaptured {
+		payment.CapturedAmount = payment.Amount
+	}
+	return payment, save(payment)
+}
+
+// Refund returns part or all of the captured amount. The payment is claimed
+// as refunding before the provider is called, so concurrent or retried
+// refunds cannot refund it twice. A declined refund releases the claim; when
+// the provider cannot be reached the payment stays refunding until its
+// refund webhook arrives, as the refund may have gone through.
+func Refund(ctx context.Context, payment models.Payment, amount float64) (models.Payment, error) {
+	provider, err := Provider(payment.Provider)
+	if err != nil {
+		return payment, err
+	}
+
+	previousStatus := payment.Status
+	result, err := db.DB.Exec(`UPDATE payments SET status = $1, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $2 AND status IN ($3, $4) AND captured_amount - refunded_amount >= $5 - 0.005`,
+		StatusRefunding, payment.ID, StatusCaptured, StatusPartiallyRefunded, amount)
+	if err != nil {
+		return payment, err
+	}
+	if claimed, _ := result.RowsAffected(); claimed == 0 {
+		return payment, ErrRefundInProgress
+	}
+	payment.Status = StatusRefunding
+
+	refund, err := provider.Refund(ctx, payment.ProviderRef, amount)
+	if err != nil {
+		return payment, err
+	}
+	if refund.Status == StatusFailed {
+		payment.Status = previousStatus
+		payment.FailureReason = refund.FailureReason
+		return payment, save(payment)
+	}
+
+	payment.Status = refund.Status
+	payment.RefundedAmount += amount
+	return payment, save(payment)
+}
+
+// Void releases the authorization of a payment that was never captured, so
+// the customer's funds are no longer held. Like Refund, it claims the payment
+// as voiding first; a declined void puts it back to authorized, and when the
+// provider cannot be reached it stays voiding until the void webhook arrives.
+func Void(ctx context.Context, payment models.Payment) (models.Payment, error) {
+	provider, err := Provider(payment.Provider)
+	if err != nil {
+		return payment, err
+	}
+
+	result, err := db.DB.Exec(`UPDATE payments SET status = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2 AND status = $3`,
+		StatusVoiding, payment.ID, StatusAuthorized)
+	if err != nil {
+		return payment, err
+	}
+	if claimed, _ := result.RowsAffected(); claimed == 0 {
+		return payment, ErrVoidInProgress
+	}
+	payment.Status = StatusVoiding
+
+	void, err := provider.Void(ctx, payment.ProviderRef)
+	if err != nil {
+		return payment, err
+	}
+	if void.Status == StatusFailed {
+		payment.Status = StatusAuthorized
+		payment.FailureReason = void.FailureReason
+		return payment, save(payment)
+	}
+
+	payment.Status = StatusVoided
+	return payment, save(payment)
+}
+
+func save(payment models.Payment) error {
+	query := `UPDATE payments SET status = $1, captured_amount = $2, refunded_amount = $3, failure_reason = $4,
+	updated_at = CURRENT_TIMESTAMP WHERE id = $5`
+	_, err := db.DB.Exec(query, payment.Status, payment.CapturedAmount, payment.RefundedAmount, payment.FailureReason, payment.ID)
+	return err
+}
+
+// ApplyWebhook updates the payment a provider callback refers to. Events are
+// recorded by ID first, so a redelivered webhook is reported as a duplicate
+// and applied only once.
+func ApplyWebhook(provider string, event WebhookEvent) (payment models.Payment, duplicate bool, err error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return payment, false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`INSERT INTO payment_webhook_events (provider, event_id, event_type) VALUES ($1, $2, $3)
+	ON CONFLICT DO NOTHING`, provider, event.EventID, event.Type)
+	if err != nil {
+		return payment, false, err
+	}
+	if inserted, _ := result.RowsAffected(); inserted == 0 {
+		return payment, true, nil
+	}
+
+	query := `SELECT ` + paymentColumns + ` FROM payments WHERE provider = $1 AND provider_ref = $2 FOR UPDATE`
+	payment, err = scanPayment(tx.QueryRow(query, provider, event.ProviderRef))
+	if err != nil {
+		return payment, false, err
+	}
+
+	switch event.Type {
+	case EventAuthorized:
+		payment.Status = StatusAuthorized
+	case EventCaptured:
+		payment.Status = StatusCaptured
+		payment.CapturedAmount = payment.Amount
+		if event.Amount > 0 {
+			payment.CapturedAmount = event.Amount
+		}
+	case EventFailed:
+		payment.Status = StatusFailed
+		payment.FailureReason = event.FailureReason
+	case EventRefunded:
+		payment.RefundedAmount += event.Amount
+		payment.Status = StatusPartiallyRefunded
+		if payment.RefundedAmount >= payment.CapturedAmount-0.005 {
+			payment.Status = StatusRefunded
+		}
+	case EventVoided:
+		payment.Status = StatusVoided
+	}
+
+	query = `UPDATE payments SET status = $1, captured_amount = $2, refunded_amount = $3, failure_reason = $4,
+	updated_at = CURRENT_TIMESTAMP WHERE id = $5`
+	_, err = tx.Exec(query, payment.Status, payment.CapturedAmount, payment.RefundedAmount, payment.FailureReason, payment.ID)
+	if err != nil {
+		return payment, false, err
+	}
+
+	return payment, false, tx.Commit()
+}
+
+// LatestForOrder returns the most recent payment attempt of an order
+func LatestForOrder(orderID int) (models.Payment, error) {
+	query := `SELECT ` + paymentColumns + ` FROM payments WHERE order_id = $1 ORDER BY id DESC LIMIT 1`
+	return scanPayment(db.DB.QueryRow(query, orderID))
+}
+
+// ForOrder returns every payment attempt of an order, oldest first
+func ForOrder(orderID int) ([]models.Payment, error) {
+	query := `SELECT ` + paymentColumns + ` FROM payments WHERE order_id = $1 ORDER BY id`
+	rows, err := db.DB.Query(query, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []models.Payment{}
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, payment)
+	}
+	return result, rows.Err()
+}
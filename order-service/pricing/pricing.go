@@ -0,0 +1,222 @@
+package pricing
+
+import (
+	"database/sql"
+	"math"
+	"order-service/models"
+	"order-service/promotions"
+	"strings"
+)
+
+// Line is an order line with what the pipeline needs from the catalog
+type Line struct {
+	promotions.Line
+	TaxClass    string
+	WeightGrams int
+}
+
+// Result is a priced order. Promotion holds the applied coupon, if any, so
+// the caller can redeem it.
+type Result struct {
+	Quote     models.PriceQuote
+	Promotion promotions.Result
+}
+
+// Rates looks up the tax rules and shipping rates an order may use.
+// Calculate reads them from the database.
+type Rates interface {
+	// TaxRules returns the rules for a tax class in a country, for any region
+	TaxRules(country, taxClass string) ([]models.TaxRule, error)
+	// ShippingRates returns the rates for a country and the catch-all rates
+	ShippingRates(country string) ([]models.ShippingRate, error)
+}
+
+// Calculate prices an order with the coupon, if any, and the rates in the
+// database. Everything runs in tx so a coupon stays locked until the order is
+// stored; quotes simply roll tx back.
+func Calculate(tx *sql.Tx, userID int, lines []Line, couponCode string, address *models.ShippingAddress) (Result, error) {
+	var promotion *promotions.Result
+	if strings.TrimSpace(couponCode) != "" {
+		promotionLines := []promotions.Line{}
+		for _, line := range lines {
+			promotionLines = append(promotionLines, line.Line)
+		}
+		applied, err := promotions.Apply(tx, couponCode, userID, promotionLines)
+		if err != nil {
+			return Result{}, err
+		}
+		promotion = &applied
+	}
+	return Price(lines, promotion, address, dbRates{tx})
+}
+
+// Price runs the pricing pipeline: subtotal, the promotion's discounts, tax
+// by the destination and each line's tax class, then shipping by total
+// weight.
+func Price(lines []Line, promotion *promotions.Result, address *models.ShippingAddress, rates Rates) (Result, error) {
+	var result Result
+	quote := models.PriceQuote{Items: []models.QuoteItem{}, ShippingAddress: address}
+
+	for _, line := range lines {
+		quote.Subtotal += line.Total()
+		quote.WeightGrams += line.WeightGrams * line.Quantity
+	}
+	quote.Subtotal = round(quote.Subtotal)
+
+	// Discounts
+	discounts := make([]float64, len(lines))
+	if promotion != nil {
+		result.Promotion = *promotion
+		quote.CouponCode = &result.Promotion.Coupon.Code
+		quote.DiscountTotal = promotion.DiscountTotal
+		quote.FreeShipping = promotion.FreeShipping
+		copy(discounts, promotion.Discounts)
+	}
+
+	// Tax on the discounted line amount; no destination means no tax
+	country, region := "", ""
+	if address != nil {
+		country, region = strings.ToUpper(address.Country), address.Region
+	}
+	taxRates := map[string]float64{}
+	for i, line := range lines {
+		taxClass := line.TaxClass
+		if taxClass == "" {
+			taxClass = "standard"
+		}
+		rate, cached := taxRates[taxClass]
+		if !cached && country != "" {
+			rules, err := rates.TaxRules(country, taxClass)
+			if err != nil {
+				return result, err
+			}
+			rate = taxRate(rules, region)
+		}
+		taxRates[taxClass] = rate
+
+		item := models.QuoteItem{
+			ProductID: line.ProductID,
+			Quantity:  line.Quantity,
+			UnitPrice: line.UnitPrice,
+			Discount:  discounts[i],
+			Tax:       round((line.Total() - discounts[i]) * rate),
+		}
+		item.LineTotal = round(line.Total() - item.Discount + item.Tax)
+		quote.TaxTotal += item.Tax
+		quote.Items = append(quote.Items, item)
+	}
+	quote.TaxTotal = round(quote.TaxTotal)
+
+	// Shipping
+	if !quote.FreeShipping {
+		shippingRates, err := rates.ShippingRates(country)
+		if err != nil {
+			return result, err
+		}
+		quote.ShippingTotal = shippingRate(shippingRates, country, quote.WeightGrams)
+	}
+
+	quote.Total = round(quote.Subtotal - quote.DiscountTotal + quote.TaxTotal + quote.ShippingTotal)
+	result.Quote = quote
+	return result, nil
+}
+
+// taxRate returns the rate of the rule for the region, falling back to the
+// rule for the whole country; no rule means no tax
+func taxRate(rules []models.TaxRule, region string) float64 {
+	rate := 0.0
+	for _, rule := range rules {
+		if rule.Region == "" {
+			rate = rule.Rate
+		} else if strings.EqualFold(rule.Region, region) {
+			return rule.Rate
+		}
+	}
+	return rate
+}
+
+// shippingRate picks the smallest weight bracket that fits, preferring
+// rates for the destination country over the catch-all ones. A rate without
+// a maximum weight fits any order but is only picked when no bracket does.
+// No matching rate means free shipping.
+func shippingRate(rates []models.ShippingRate, country string, weightGrams int) float64 {
+	var best *models.ShippingRate
+	for i := range rates {
+		rate := &rates[i]
+		if rate.Country != "" && !strings.EqualFold(rate.Country, country) {
+			continue
+		}
+		if rate.MaxWeightGrams != nil && *rate.MaxWeightGrams < weightGrams {
+			continue
+		}
+		if best == nil || preferredRate(rate, best) {
+			best = rate
+		}
+	}
+	if best == nil {
+		return 0
+	}
+	return best.Rate
+}
+
+// Helper function to tell whether a fitting shipping rate beats another
+func preferredRate(rate, other *models.ShippingRate) bool {
+	if (rate.Country != "") != (other.Country != "") {
+		return rate.Country != ""
+	}
+	if rate.MaxWeightGrams == nil || other.MaxWeightGrams == nil {
+		return other.MaxWeightGrams == nil && rate.MaxWeightGrams != nil
+	}
+	return *rate.MaxWeightGrams < *other.MaxWeightGrams
+}
+
+// dbRates reads the rates within the pricing transaction
+type dbRates struct {
+	tx *sql.Tx
+}
+
+func (r dbRates) TaxRules(country, taxClass string) ([]models.TaxRule, error) {
+	rows, err := r.tx.Query(`SELECT id, country, region, tax_class, rate FROM tax_rules WHERE country = $1 AND tax_class = $2`,
+		country, taxClass)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []models.TaxRule{}
+	for rows.Next() {
+		var rule models.TaxRule
+		if err := rows.Scan(&rule.ID, &rule.Country, &rule.Region, &rule.TaxClass, &rule.Rate); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func (r dbRates) ShippingRates(country string) ([]models.ShippingRate, error) {
+	rows, err := r.tx.Query(`SELECT id, country, max_weight_grams, rate FROM shipping_rates WHERE country = '' OR country = $1`, country)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := []models.ShippingRate{}
+	for rows.Next() {
+		var rate models.ShippingRate
+		var maxWeightGrams sql.NullInt64
+		if err := rows.Scan(&rate.ID, &rate.Country, &maxWeightGrams, &rate.Rate); err != nil {
+			return nil, err
+		}
+		if maxWeightGrams.Valid {
+			max := int(maxWeightGrams.Int64)
+			rate.MaxWeightGrams = &max
+		}
+		rates = append(rates, rate)
+	}
+	return rates, rows.Err()
+}
+
+func round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
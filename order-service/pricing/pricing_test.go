@@ -0,0 +1,200 @@
+package pricing
+
+import (
+	"order-service/models"
+	"order-service/promotions"
+	"reflect"
+	"testing"
+)
+
+// fakeRates serves rates from memory the way dbRates queries them
+type fakeRates struct {
+	taxRules      []models.TaxRule
+	shippingRates []models.ShippingRate
+}
+
+func (r fakeRates) TaxRules(country, taxClass string) ([]models.TaxRule, error) {
+	rules := []models.TaxRule{}
+	for _, rule := range r.taxRules {
+		if rule.Country == country && rule.TaxClass == taxClass {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+func (r fakeRates) ShippingRates(country string) ([]models.ShippingRate, error) {
+	rates := []models.ShippingRate{}
+	for _, rate := range r.shippingRates {
+		if rate.Country == "" || rate.Country == country {
+			rates = append(rates, rate)
+		}
+	}
+	return rates, nil
+}
+
+func grams(n int) *int {
+	return &n
+}
+
+func TestPrice(t *testing.T) {
+	rates := fakeRates{
+		taxRules: []models.TaxRule{
+			{Country: "US", TaxClass: "standard", Rate: 0.05},
+			{Country: "US", Region: "CA", TaxClass: "standard", Rate: 0.0725},
+			{Country: "US", TaxClass: "reduced", Rate: 0.02},
+			{Country: "DE", TaxClass: "standard", Rate: 0.19},
+		},
+		shippingRates: []models.ShippingRate{
+			{Country: "", MaxWeightGrams: grams(2000), Rate: 12},
+			{Country: "", Rate: 25},
+			{Country: "US", MaxWeightGrams: grams(5000), Rate: 10},
+			{Country: "US", MaxWeightGrams: grams(1000), Rate: 5},
+			{Country: "US", Rate: 30},
+		},
+	}
+
+	// An unclassified line is taxed as standard
+	order := func(quantity int) []Line {
+		return []Line{
+			{Line: promotions.Line{ProductID: 1, UnitPrice: 10, Quantity: quantity}, WeightGrams: 200},
+			{Line: promotions.Line{ProductID: 2, UnitPrice: 5, Quantity: 1}, TaxClass: "reduced", WeightGrams: 500},
+		}
+	}
+	address := func(country, region string) *models.ShippingAddress {
+		return &models.ShippingAddress{Country: country, Region: region}
+	}
+
+	tests := []struct {
+		name       string
+		lines      []Line
+		promotion  *promotions.Result
+		address    *models.ShippingAddress
+		taxes      []float64
+		lineTotals []float64
+		shipping   float64
+		total      float64
+	}{
+		{
+			name:       "no destination has no tax and catch-all shipping",
+			lines:      order(2),
+			taxes:      []float64{0, 0},
+			lineTotals: []float64{20, 5},
+			shipping:   12,
+			total:      37,
+		},
+		{
+			name:       "country-wide tax per class",
+			lines:      order(2),
+			address:    address("US", "NY"),
+			taxes:      []float64{1, 0.1},
+			lineTotals: []float64{21, 5.1},
+			shipping:   5,
+			total:      31.1,
+		},
+		{
+			name:       "regional tax overrides the country rule",
+			lines:      order(2),
+			address:    address("us", "ca"),
+			taxes:      []float64{1.45, 0.1},
+			lineTotals: []float64{21.45, 5.1},
+			shipping:   5,
+			total:      31.55,
+		},
+		{
+			name:       "class without a rule is not taxed",
+			lines:      order(2),
+			address:    address("DE", ""),
+			taxes:      []float64{3.8, 0},
+			lineTotals: []float64{23.8, 5},
+			shipping:   12,
+			total:      40.8,
+		},
+		{
+			name:       "smallest bracket that fits the weight",
+			lines:      order(10),
+			address:    address("US", "NY"),
+			taxes:      []float64{5, 0.1},
+			lineTotals: []float64{105, 5.1},
+			shipping:   10,
+			total:      120.1,
+		},
+		{
+			name:       "flat country rate above the largest bracket",
+			lines:      order(49),
+			address:    address("US", "NY"),
+			taxes:      []float64{24.5, 0.1},
+			lineTotals: []float64{514.5, 5.1},
+			shipping:   30,
+			total:      549.6,
+		},
+		{
+			name:       "flat catch-all rate above the catch-all bracket",
+			lines:      order(10),
+			address:    address("FR", ""),
+			taxes:      []float64{0, 0},
+			lineTotals: []float64{100, 5},
+			shipping:   25,
+			total:      130,
+		},
+		{
+			name:  "tax on the discounted amount",
+			lines: order(2),
+			promotion: &promotions.Result{
+				Coupon:        models.Coupon{Code: "TENOFF", Type: models.CouponTypePercentage, Value: 10},
+				Discounts:     []float64{2, 0.5},
+				DiscountTotal: 2.5,
+			},
+			address:    address("US", "NY"),
+			taxes:      []float64{0.9, 0.09},
+			lineTotals: []float64{18.9, 4.59},
+			shipping:   5,
+			total:      28.49,
+		},
+		{
+			name:  "free shipping",
+			lines: order(2),
+			promotion: &promotions.Result{
+				Coupon:       models.Coupon{Code: "SHIPFREE", Type: models.CouponTypeFreeShipping},
+				Discounts:    []float64{0, 0},
+				FreeShipping: true,
+			},
+			address:    address("US", "NY"),
+			taxes:      []float64{1, 0.1},
+			lineTotals: []float64{21, 5.1},
+			shipping:   0,
+			total:      26.1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Price(tt.lines, tt.promotion, tt.address, rates)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			quote := result.Quote
+
+			taxes, lineTotals := []float64{}, []float64{}
+			for _, item := range quote.Items {
+				taxes = append(taxes, item.Tax)
+				lineTotals = append(lineTotals, item.LineTotal)
+			}
+			if !reflect.DeepEqual(taxes, tt.taxes) {
+				t.Errorf("taxes = %v, want %v", taxes, tt.taxes)
+			}
+			if !reflect.DeepEqual(lineTotals, tt.lineTotals) {
+				t.Errorf("line totals = %v, want %v", lineTotals, tt.lineTotals)
+			}
+			if quote.ShippingTotal != tt.shipping {
+				t.Errorf("shipping = %v, want %v", quote.ShippingTotal, tt.shipping)
+			}
+			if quote.Total != tt.total {
+				t.Errorf("total = %v, want %v", quote.Total, tt.total)
+			}
+			if tt.promotion != nil && (quote.CouponCode == nil || *quote.CouponCode != tt.promotion.Coupon.Code) {
+				t.Errorf("coupon code = %v, want %q", quote.CouponCode, tt.promotion.Coupon.Code)
+			}
+		})
+	}
+}
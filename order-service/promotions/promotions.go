@@ -0,0 +1,227 @@
+package promotions
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"order-service/models"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// CouponError explains why a coupon cannot be used; it is safe to show to
+// the customer
+type CouponError struct {
+	Message string
+}
+
+func (e *CouponError) Error() string {
+	return e.Message
+}
+
+// Line is an order line as seen by the promotion engine
+type Line struct {
+	ProductID int
+	Category  string
+	UnitPrice float64
+	Quantity  int
+}
+
+func (l Line) Total() float64 {
+	return l.UnitPrice * float64(l.Quantity)
+}
+
+// Result is the outcome of applying a coupon. Discounts holds the discount
+// per line, in the order of the lines passed in.
+type Result struct {
+	Coupon        models.Coupon
+	Discounts     []float64
+	DiscountTotal float64
+	FreeShipping  bool
+}
+
+const Columns = `id, code, type, value, min_order_value, max_uses, max_uses_per_user, starts_at, expires_at,
+product_ids, categories, active, created_at,
+(SELECT COUNT(*) FROM coupon_redemptions r WHERE r.coupon_id = coupons.id)`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func Scan(row rowScanner) (models.Coupon, error) {
+	var coupon models.Coupon
+	var maxUses, maxUsesPerUser sql.NullInt64
+	var startsAt, expiresAt sql.NullString
+	var productIDs pq.Int64Array
+	var categories pq.StringArray
+	err := row.Scan(&coupon.ID, &coupon.Code, &coupon.Type, &coupon.Value, &coupon.MinOrderValue, &maxUses, &maxUsesPerUser,
+		&startsAt, &expiresAt, &productIDs, &categories, &coupon.Active, &coupon.CreatedAt, &coupon.TimesRedeemed)
+	if err != nil {
+		return coupon, err
+	}
+
+	if maxUses.Valid {
+		n := int(maxUses.Int64)
+		coupon.MaxUses = &n
+	}
+	if maxUsesPerUser.Valid {
+		n := int(maxUsesPerUser.Int64)
+		coupon.MaxUsesPerUser = &n
+	}
+	if startsAt.Valid {
+		coupon.StartsAt = &startsAt.String
+	}
+	if expiresAt.Valid {
+		coupon.ExpiresAt = &expiresAt.String
+	}
+	coupon.ProductIDs = []int{}
+	for _, id := range productIDs {
+		coupon.ProductIDs = append(coupon.ProductIDs, int(id))
+	}
+	coupon.Categories = []string(categories)
+	if coupon.Categories == nil {
+		coupon.Categories = []string{}
+	}
+	return coupon, nil
+}
+
+// Apply validates the coupon for a user's order and computes the discounts.
+// The coupon row is locked until tx ends, so usage limits hold under
+// concurrent checkouts; Redeem must be called in the same transaction.
+func Apply(tx *sql.Tx, code string, userID int, lines []Line) (Result, error) {
+	var result Result
+
+	query := `SELECT ` + Columns + ` FROM coupons WHERE code = $1 FOR UPDATE`
+	coupon, err := Scan(tx.QueryRow(query, strings.ToUpper(strings.TrimSpace(code))))
+	if err == sql.ErrNoRows {
+		return result, &CouponError{"Coupon code is not valid"}
+	}
+	if err != nil {
+		return result, err
+	}
+	result.Coupon = coupon
+
+	// Validity window is checked by the database clock
+	var started, expired bool
+	err = tx.QueryRow(`SELECT COALESCE(starts_at <= CURRENT_TIMESTAMP, TRUE), COALESCE(expires_at <= CURRENT_TIMESTAMP, FALSE)
+	FROM coupons WHERE id = $1`, coupon.ID).Scan(&started, &expired)
+	if err != nil {
+		return result, err
+	}
+	if !coupon.Active || !started {
+		return result, &CouponError{"Coupon code is not valid"}
+	}
+	if expired {
+		return result, &CouponError{"Coupon has expired"}
+	}
+
+	if coupon.MaxUses != nil && coupon.TimesRedeemed >= *coupon.MaxUses {
+		return result, &CouponError{"Coupon has reached its usage limit"}
+	}
+	if coupon.MaxUsesPerUser != nil {
+		var used int
+		err = tx.QueryRow(`SELECT COUNT(*) FROM coupon_redemptions WHERE coupon_id = $1 AND user_id = $2`,
+			coupon.ID, userID).Scan(&used)
+		if err != nil {
+			return result, err
+		}
+		if used >= *coupon.MaxUsesPerUser {
+			return result, &CouponError{"You have already used this coupon"}
+		}
+	}
+
+	return Discount(coupon, lines)
+}
+
+// Discount computes what a valid coupon takes off an order. Apply checks the
+// coupon can be used before calling it.
+func Discount(coupon models.Coupon, lines []Line) (Result, error) {
+	result := Result{Coupon: coupon}
+
+	subtotal := 0.0
+	eligibleTotal := 0.0
+	eligible := make([]bool, len(lines))
+	for i, line := range lines {
+		subtotal += line.Total()
+		eligible[i] = appliesTo(coupon, line)
+		if eligible[i] {
+			eligibleTotal += line.Total()
+		}
+	}
+	if subtotal < coupon.MinOrderValue {
+		return result, &CouponError{fmt.Sprintf("Coupon requires a minimum order value of %.2f", coupon.MinOrderValue)}
+	}
+	if eligibleTotal == 0 {
+		return result, &CouponError{"Coupon does not apply to any item in the order"}
+	}
+
+	result.Discounts = make([]float64, len(lines))
+	switch coupon.Type {
+	case models.CouponTypePercentage:
+		for i, line := range lines {
+			if eligible[i] {
+				result.Discounts[i] = round(line.Total() * coupon.Value / 100)
+			}
+		}
+	case models.CouponTypeFixedAmount:
+		// Spread the amount over eligible lines by value; the last eligible
+		// line absorbs the rounding so the parts add up exactly
+		amount := math.Min(coupon.Value, eligibleTotal)
+		remaining := round(amount)
+		last := -1
+		for i := range lines {
+			if eligible[i] {
+				last = i
+			}
+		}
+		for i, line := range lines {
+			if !eligible[i] {
+				continue
+			}
+			if i == last {
+				result.Discounts[i] = remaining
+				break
+			}
+			share := round(amount * line.Total() / eligibleTotal)
+			result.Discounts[i] = share
+			remaining = round(remaining - share)
+		}
+	case models.CouponTypeFreeShipping:
+		result.FreeShipping = true
+	}
+
+	for _, discount := range result.Discounts {
+		result.DiscountTotal += discount
+	}
+	result.DiscountTotal = round(result.DiscountTotal)
+	return result, nil
+}
+
+// Redeem records the coupon use for an order
+func Redeem(tx *sql.Tx, couponID, orderID, userID int) error {
+	_, err := tx.Exec(`INSERT INTO coupon_redemptions (coupon_id, order_id, user_id) VALUES ($1, $2, $3)`,
+		couponID, orderID, userID)
+	return err
+}
+
+func appliesTo(coupon models.Coupon, line Line) bool {
+	if len(coupon.ProductIDs) == 0 && len(coupon.Categories) == 0 {
+		return true
+	}
+	for _, id := range coupon.ProductIDs {
+		if id == line.ProductID {
+			return true
+		}
+	}
+	for _, category := range coupon.Categories {
+		if line.Category != "" && strings.EqualFold(category, line.Category) {
+			return true
+		}
+	}
+	return false
+}
+
+func round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,120 @@
+package promotions
+
+import (
+	"errors"
+	"order-service/models"
+	"reflect"
+	"testing"
+)
+
+func TestDiscount(t *testing.T) {
+	lines := []Line{
+		{ProductID: 1, Category: "Books", UnitPrice: 10, Quantity: 1},
+		{ProductID: 2, Category: "Games", UnitPrice: 10, Quantity: 1},
+		{ProductID: 3, Category: "Books", UnitPrice: 10, Quantity: 1},
+	}
+
+	tests := []struct {
+		name         string
+		coupon       models.Coupon
+		lines        []Line
+		discounts    []float64
+		total        float64
+		freeShipping bool
+		err          string
+	}{
+		{
+			name:      "percentage on every line",
+			coupon:    models.Coupon{Type: models.CouponTypePercentage, Value: 15},
+			lines:     []Line{{ProductID: 1, UnitPrice: 19.99, Quantity: 3}, {ProductID: 2, UnitPrice: 5, Quantity: 1}},
+			discounts: []float64{9, 0.75},
+			total:     9.75,
+		},
+		{
+			name:      "percentage on a category only",
+			coupon:    models.Coupon{Type: models.CouponTypePercentage, Value: 50, Categories: []string{"books"}},
+			lines:     lines,
+			discounts: []float64{5, 0, 5},
+			total:     10,
+		},
+		{
+			name:      "percentage on a product only",
+			coupon:    models.Coupon{Type: models.CouponTypePercentage, Value: 50, ProductIDs: []int{2}},
+			lines:     lines,
+			discounts: []float64{0, 5, 0},
+			total:     5,
+		},
+		{
+			name:      "fixed amount split by value",
+			coupon:    models.Coupon{Type: models.CouponTypeFixedAmount, Value: 6},
+			lines:     []Line{{ProductID: 1, UnitPrice: 20, Quantity: 1}, {ProductID: 2, UnitPrice: 10, Quantity: 1}},
+			discounts: []float64{4, 2},
+			total:     6,
+		},
+		{
+			name:      "fixed amount last line absorbs rounding",
+			coupon:    models.Coupon{Type: models.CouponTypeFixedAmount, Value: 10},
+			lines:     lines,
+			discounts: []float64{3.33, 3.33, 3.34},
+			total:     10,
+		},
+		{
+			name:      "fixed amount last eligible line absorbs rounding",
+			coupon:    models.Coupon{Type: models.CouponTypeFixedAmount, Value: 5, Categories: []string{"Books"}, ProductIDs: []int{2}},
+			lines:     append(lines, Line{ProductID: 4, Category: "Toys", UnitPrice: 10, Quantity: 1}),
+			discounts: []float64{1.67, 1.67, 1.66, 0},
+			total:     5,
+		},
+		{
+			name:      "fixed amount capped at the eligible total",
+			coupon:    models.Coupon{Type: models.CouponTypeFixedAmount, Value: 50, ProductIDs: []int{1}},
+			lines:     lines,
+			discounts: []float64{10, 0, 0},
+			total:     10,
+		},
+		{
+			name:         "free shipping",
+			coupon:       models.Coupon{Type: models.CouponTypeFreeShipping},
+			lines:        lines,
+			discounts:    []float64{0, 0, 0},
+			freeShipping: true,
+		},
+		{
+			name:   "below the minimum order value",
+			coupon: models.Coupon{Type: models.CouponTypePercentage, Value: 10, MinOrderValue: 50},
+			lines:  lines,
+			err:    "Coupon requires a minimum order value of 50.00",
+		},
+		{
+			name:   "no eligible line",
+			coupon: models.Coupon{Type: models.CouponTypePercentage, Value: 10, Categories: []string{"Toys"}},
+			lines:  lines,
+			err:    "Coupon does not apply to any item in the order",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Discount(tt.coupon, tt.lines)
+			if tt.err != "" {
+				var couponErr *CouponError
+				if !errors.As(err, &couponErr) || couponErr.Message != tt.err {
+					t.Fatalf("error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(result.Discounts, tt.discounts) {
+				t.Errorf("discounts = %v, want %v", result.Discounts, tt.discounts)
+			}
+			if result.DiscountTotal != tt.total {
+				t.Errorf("discount total = %v, want %v", result.DiscountTotal, tt.total)
+			}
+			if result.FreeShipping != tt.freeShipping {
+				t.Errorf("free shipping = %v, want %v", result.FreeShipping, tt.freeShipping)
+			}
+		})
+	}
+}
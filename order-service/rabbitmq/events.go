@@ -5,10 +5,12 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"order-service/db"
 	"order-service/models"
 	"order-service/utils"
 	"sync"
 
+	"github.com/lib/pq"
 	"github.com/streadway/amqp"
 )
 
@@ -20,7 +22,7 @@ func EmitOrderPlaced(order models.OrderPlacedEvent) {
 	}
 
 	err = Channel.Publish(
-		"",             // exchange
+		Exchange,       // exchange
 		"order_placed", // routing key
 		false,          // mandatory
 		false,          // immediate
@@ -36,10 +38,7 @@ func EmitOrderPlaced(order models.OrderPlacedEvent) {
 	}
 }
 
-func EmitOrderShipped(orderID int) {
-	event := models.OrderShippedEvent{
-		OrderID: orderID,
-	}
+func EmitOrderShipped(event models.OrderShippedEvent) {
 	body, err := json.Marshal(event)
 	if err != nil {
 		log.Printf("Failed to serialize order shipped event: %v", err)
@@ -47,7 +46,7 @@ func EmitOrderShipped(orderID int) {
 	}
 
 	err = Channel.Publish(
-		"",              // exchange
+		Exchange,        // exchange
 		"order_shipped", // routing key
 		false,           // mandatory
 		false,           // immediate
@@ -63,6 +62,80 @@ func EmitOrderShipped(orderID int) {
 	}
 }
 
+func EmitOrderCancelled(event models.OrderCancelledEvent) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to serialize order cancelled event: %v", err)
+		return
+	}
+
+	err = Channel.Publish(
+		Exchange,          // exchange
+		"order_cancelled", // routing key
+		false,             // mandatory
+		false,             // immediate
+		amqp.Publishing{
+			ContentType:  "application/json",
+			Body:         body,
+			DeliveryMode: amqp.Persistent,
+		})
+	if err != nil {
+		log.Printf("Failed to publish order_cancelled event: %v", err)
+	} else {
+		log.Printf("Order Cancelled Event emitted: %s", body)
+	}
+}
+
+func EmitReturnInspected(event models.ReturnInspectedEvent) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to serialize return inspected event: %v", err)
+		return
+	}
+
+	err = Channel.Publish(
+		Exchange,           // exchange
+		"return_inspected", // routing key
+		false,              // mandatory
+		false,              // immediate
+		amqp.Publishing{
+			ContentType:  "application/json",
+			Body:         body,
+			DeliveryMode: amqp.Persistent,
+		})
+	if err != nil {
+		log.Printf("Failed to publish return_inspected event: %v", err)
+	} else {
+		log.Printf("Return Inspected Event emitted: %s", body)
+	}
+}
+
+// EmitPaymentEvent publishes a payment lifecycle event; queue is one of
+// payment_succeeded, payment_failed or payment_refunded
+func EmitPaymentEvent(queue string, event models.PaymentEvent) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to serialize %s event: %v", queue, err)
+		return
+	}
+
+	err = Channel.Publish(
+		Exchange, // exchange
+		queue,    // routing key
+		false,    // mandatory
+		false,    // immediate
+		amqp.Publishing{
+			ContentType:  "application/json",
+			Body:         body,
+			DeliveryMode: amqp.Persistent,
+		})
+	if err != nil {
+		log.Printf("Failed to publish %s event: %v", queue, err)
+	} else {
+		log.Printf("Payment Event %s emitted: %s", queue, body)
+	}
+}
+
 var ProductCatalog = make(map[int]models.Product)
 var UserRegistry = make(map[int]models.User)
 var mutex = &sync.RWMutex{}
@@ -82,6 +155,16 @@ func ListenForEvents() {
 
 	// Listen for "Product Deleted" events
 	go listenForProductDeleted()
+
+	// Listen for "Product Restored" events
+	go listenForProductRestored()
+
+	// Listen for payment outcomes to move orders along
+	go listenForPaymentOutcome("payment_succeeded", models.OrderStatusPaid)
+	go listenForPaymentOutcome("payment_failed", models.OrderStatusPaymentFailed)
+
+	// Listen for "Return Restocked" events
+	go listenForReturnRestocked()
 }
 
 func listenForProductCreated() {
@@ -199,15 +282,42 @@ func listenForProductUpdated() {
 
 	go func() {
 		for d := range msgs {
-			var product models.Product
-			err := json.Unmarshal(d.Body, &product)
+			var event models.ProductUpdatedEvent
+			err := json.Unmarshal(d.Body, &event)
 			if err != nil {
 				log.Printf("Failed to parse product updated event: %v", err)
 				continue
 			}
-			log.Printf("Received Product Updated Event: %+v", product)
+			log.Printf("Received Product Updated Event: %s", d.Body)
+
+			// Merge the changed fields, ignoring events older than what we hold
 			mutex.Lock()
-			ProductCatalog[product.ID] = product
+			if product, exists := ProductCatalog[event.ID]; !exists {
+				log.Printf("Product %d not found in ProductCatalog", event.ID)
+			} else if event.Version <= product.Version {
+				log.Printf("Ignoring stale update for product %d (version %d <= %d)", event.ID, event.Version, product.Version)
+			} else {
+				if event.Name != nil {
+					product.Name = *event.Name
+				}
+				if event.Description != nil {
+					product.Description = *event.Description
+				}
+				if event.Price != nil {
+					product.Price = *event.Price
+				}
+				if event.Category != nil {
+					product.Category = *event.Category
+				}
+				if event.TaxClass != nil {
+					product.TaxClass = *event.TaxClass
+				}
+				if event.WeightGrams != nil {
+					product.WeightGrams = *event.WeightGrams
+				}
+				product.Version = event.Version
+				ProductCatalog[event.ID] = product
+			}
 			mutex.Unlock()
 		}
 	}()
@@ -244,6 +354,145 @@ func listenForProductDeleted() {
 	}()
 }
 
+func listenForProductRestored() {
+	msgs, err := Channel.Consume(
+		"product_restored", // queue
+		"",                 // consumer
+		true,               // auto-ack
+		false,              // exclusive
+		false,              // no-local
+		false,              // no-wait
+		nil,                // args
+	)
+	if err != nil {
+		log.Fatalf("Failed to register consumer for product_restored: %v", err)
+	}
+
+	go func() {
+		for d := range msgs {
+			var product models.Product
+			err := json.Unmarshal(d.Body, &product)
+			if err != nil {
+				log.Printf("Failed to parse product restored event: %v", err)
+				continue
+			}
+			log.Printf("Received Product Restored Event: %+v", product)
+			mutex.Lock()
+			ProductCatalog[product.ID] = product
+			mutex.Unlock()
+		}
+	}()
+}
+
+// listenForPaymentOutcome sets the order status for each payment event on
+// queue. Only unpaid orders are touched, so redelivered or late events
+// cannot undo a later transition.
+func listenForPaymentOutcome(queue, status string) {
+	msgs, err := Channel.Consume(
+		queue, // queue
+		"",    // consumer
+		true,  // auto-ack
+		false, // exclusive
+		false, // no-local
+		false, // no-wait
+		nil,   // args
+	)
+	if err != nil {
+		log.Fatalf("Failed to register consumer for %s: %v", queue, err)
+	}
+
+	go func() {
+		for d := range msgs {
+			var event models.PaymentEvent
+			err := json.Unmarshal(d.Body, &event)
+			if err != nil {
+				log.Printf("Failed to parse %s event: %v", queue, err)
+				continue
+			}
+			log.Printf("Received %s Event: %+v", queue, event)
+
+			_, err = db.DB.Exec(
+				`UPDATE orders SET status = $1 WHERE id = $2 AND status IN ($3, $4)`,
+				status, event.OrderID, models.OrderStatusPlaced, models.OrderStatusPaymentFailed,
+			)
+			if err != nil {
+				log.Printf("Failed to set order %d to %s: %v", event.OrderID, status, err)
+			}
+		}
+	}()
+}
+
+// listenForReturnRestocked marks the returned items product-service has put
+// back into stock
+func listenForReturnRestocked() {
+	msgs, err := Channel.Consume(
+		"return_restocked", // queue
+		"",                 // consumer
+		true,               // auto-ack
+		false,              // exclusive
+		false,              // no-local
+		false,              // no-wait
+		nil,                // args
+	)
+	if err != nil {
+		log.Fatalf("Failed to register consumer for return_restocked: %v", err)
+	}
+
+	go func() {
+		for d := range msgs {
+			var event models.ReturnRestockedEvent
+			err := json.Unmarshal(d.Body, &event)
+			if err != nil {
+				log.Printf("Failed to parse return restocked event: %v", err)
+				continue
+			}
+			log.Printf("Received Return Restocked Event: %+v", event)
+
+			productIDs := []int{}
+			for _, item := range event.Items {
+				productIDs = append(productIDs, item.ProductID)
+			}
+			_, err = db.DB.Exec(`UPDATE return_items ri SET restocked_at = COALESCE(ri.restocked_at, CURRENT_TIMESTAMP)
+			FROM order_items oi WHERE oi.id = ri.order_item_id AND ri.return_id = $1 AND ri.restock AND oi.product_id = ANY($2)`,
+				event.ReturnID, pq.Array(productIDs))
+			if err != nil {
+				log.Printf("Failed to mark return %d as restocked: %v", event.ReturnID, err)
+			}
+		}
+	}()
+}
+
+// ListenForOrderStockRejected calls reject for each order product-service
+// could not take the stock of. The handler lives with the other order
+// handlers, as rejecting an order cancels and refunds it.
+func ListenForOrderStockRejected(reject func(models.OrderStockRejectedEvent)) {
+	msgs, err := Channel.Consume(
+		"order_stock_rejected", // queue
+		"",                     // consumer
+		true,                   // auto-ack
+		false,                  // exclusive
+		false,                  // no-local
+		false,                  // no-wait
+		nil,                    // args
+	)
+	if err != nil {
+		log.Fatalf("Failed to register consumer for order_stock_rejected: %v", err)
+	}
+
+	go func() {
+		for d := range msgs {
+			var event models.OrderStockRejectedEvent
+			err := json.Unmarshal(d.Body, &event)
+			if err != nil {
+				log.Printf("Failed to parse order stock rejected event: %v", err)
+				continue
+			}
+			log.Printf("Received Order Stock Rejected Event: %+v", event)
+			reject(event)
+		}
+	}()
+}
+
 func LoadExistingProducts() {
 	url := utils.ProductServiceURL + "/products"
 	resp, err := utils.HTTPClient.Get(url)
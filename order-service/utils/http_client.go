@@ -6,9 +6,11 @@ import (
 )
 
 var ProductServiceURL string
+var UserServiceURL string
 
 func InitHTTPClient() {
 	ProductServiceURL = getEnv("PRODUCT_SERVICE_URL", "http://localhost:8082")
+	UserServiceURL = getEnv("USER_SERVICE_URL", "http://localhost:8081")
 }
 
 var HTTPClient = &http.Client{
@@ -0,0 +1,18 @@
+package utils
+
+import (
+	"log"
+	"strconv"
+)
+
+// ReturnWindowDays is how many days after delivery items can be returned
+var ReturnWindowDays = getEnvInt("RETURN_WINDOW_DAYS", 30)
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(getEnv(key, strconv.Itoa(defaultValue)))
+	if err != nil {
+		log.Printf("Invalid %s, using %d", key, defaultValue)
+		return defaultValue
+	}
+	return value
+}
@@ -43,7 +43,7 @@ func Init() {
 		log.Fatalf("Could not connect to the database after %d attempts", maxRetries)
 	}
 
-	// Create products table if not exists
+	// Create products, stock_movements and product_images tables if not exists
 	createTable()
 }
 
@@ -56,10 +56,55 @@ func createTable() {
         price DECIMAL(10,2) NOT NULL,
         inventory INT NOT NULL,
         created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-    );`
+    );
+    ALTER TABLE products ADD COLUMN IF NOT EXISTS reorder_threshold INT NOT NULL DEFAULT 0;
+    ALTER TABLE products ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
+    ALTER TABLE products ADD COLUMN IF NOT EXISTS archived_at TIMESTAMP WITH TIME ZONE;
+    ALTER TABLE products ADD COLUMN IF NOT EXISTS category VARCHAR(100) NOT NULL DEFAULT '';
+    ALTER TABLE products ADD COLUMN IF NOT EXISTS tax_class VARCHAR(50) NOT NULL DEFAULT 'standard';
+    ALTER TABLE products ADD COLUMN IF NOT EXISTS weight_grams INT NOT NULL DEFAULT 0;
+    CREATE TABLE IF NOT EXISTS stock_movements (
+        id SERIAL PRIMARY KEY,
+        product_id INT NOT NULL,
+        change INT NOT NULL,
+        reason VARCHAR(50) NOT NULL,
+        actor VARCHAR(100) NOT NULL,
+        order_id INT,
+        note TEXT NOT NULL DEFAULT '',
+        resulting_inventory INT NOT NULL,
+        created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+    );
+    CREATE INDEX IF NOT EXISTS stock_movements_product_id_idx ON stock_movements (product_id, id);
+    CREATE INDEX IF NOT EXISTS stock_movements_order_id_idx ON stock_movements (order_id, product_id) WHERE order_id IS NOT NULL;
+    ALTER TABLE stock_movements ADD COLUMN IF NOT EXISTS return_id INT;
+    CREATE UNIQUE INDEX IF NOT EXISTS stock_movements_return_id_idx ON stock_movements (return_id, product_id) WHERE return_id IS NOT NULL;
+    CREATE TABLE IF NOT EXISTS product_images (
+        id SERIAL PRIMARY KEY,
+        product_id INT NOT NULL REFERENCES products(id),
+        position INT NOT NULL,
+        storage_key VARCHAR(255) NOT NULL,
+        thumbnail_key VARCHAR(255) NOT NULL,
+        content_type VARCHAR(50) NOT NULL,
+        width INT NOT NULL,
+        height INT NOT NULL,
+        created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+    );
+    CREATE INDEX IF NOT EXISTS product_images_product_id_idx ON product_images (product_id, position);`
 	_, err := DB.Exec(query)
 	if err != nil {
-		log.Fatalf("Failed to create products table: %v", err)
+		log.Fatalf("Failed to create product service tables: %v", err)
+	}
+
+	// Products created before the ledger existed get an opening balance so
+	// that their inventory always equals the sum of their movements
+	query = `
+    INSERT INTO stock_movements (product_id, change, reason, actor, note, resulting_inventory)
+    SELECT p.id, p.inventory, 'initial', 'system', 'Opening balance', p.inventory
+    FROM products p
+    WHERE NOT EXISTS (SELECT 1 FROM stock_movements m WHERE m.product_id = p.id);`
+	_, err = DB.Exec(query)
+	if err != nil {
+		log.Fatalf("Failed to backfill stock_movements: %v", err)
 	}
 }
 
@@ -0,0 +1,376 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"net/http"
+	"product-service/db"
+	"product-service/models"
+	"product-service/storage"
+	"product-service/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+const (
+	maxImageSize = 10 << 20 // 10 MB
+	// maxImagePixels bounds the decoded size, as a small file can declare
+	// huge dimensions
+	maxImagePixels = 40_000_000
+	thumbnailSize  = 256
+)
+
+var imageExtensions = map[string]string{
+	"jpeg": "jpg",
+	"png":  "png",
+	"gif":  "gif",
+}
+
+func UploadProductImage(c *gin.Context) {
+	// Authentication
+	username, exists := c.Get("username")
+	if !exists || username != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	var archivedAt sql.NullString
+	err = db.DB.QueryRow(`SELECT archived_at FROM products WHERE id = $1`, id).Scan(&archivedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve product"})
+		}
+		return
+	}
+	if archivedAt.Valid {
+		c.JSON(http.StatusGone, gin.H{"error": "Product has been archived"})
+		return
+	}
+
+	fileHeader, err := c.FormFile("image")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Form file 'image' is required"})
+		return
+	}
+	if fileHeader.Size > maxImageSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Image must be at most 10 MB"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read image"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxImageSize+1))
+	if err != nil || len(data) > maxImageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read image"})
+		return
+	}
+
+	// The header is checked first so oversized images are never decoded
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Image must be a JPEG, PNG or GIF"})
+		return
+	}
+	if config.Width*config.Height > maxImagePixels {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Image must be at most 40 megapixels"})
+		return
+	}
+
+	// Decoding validates the upload is really an image in a supported format
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Image must be a JPEG, PNG or GIF"})
+		return
+	}
+	ext, ok := imageExtensions[format]
+	if !ok {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Image must be a JPEG, PNG or GIF"})
+		return
+	}
+
+	var thumb bytes.Buffer
+	err = jpeg.Encode(&thumb, utils.Thumbnail(img, thumbnailSize), &jpeg.Options{Quality: 85})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate thumbnail"})
+		return
+	}
+
+	name, err := randomName()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate image name"})
+		return
+	}
+	storageKey := fmt.Sprintf("products/%d/%s.%s", id, name, ext)
+	thumbnailKey := fmt.Sprintf("products/%d/%s_thumb.jpg", id, name)
+	contentType := "image/" + format
+
+	ctx := c.Request.Context()
+	if err := storage.Store.Put(ctx, storageKey, bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+		log.Printf("Failed to store image %s: %v", storageKey, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image"})
+		return
+	}
+	if err := storage.Store.Put(ctx, thumbnailKey, bytes.NewReader(thumb.Bytes()), int64(thumb.Len()), "image/jpeg"); err != nil {
+		log.Printf("Failed to store thumbnail %s: %v", thumbnailKey, err)
+		deleteBlobs(c, storageKey)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image"})
+		return
+	}
+
+	bounds := img.Bounds()
+	query := `INSERT INTO product_images (product_id, position, storage_key, thumbnail_key, content_type, width, height)
+	VALUES ($1, (SELECT COALESCE(MAX(position) + 1, 0) FROM product_images WHERE product_id = $1), $2, $3, $4, $5, $6)
+	RETURNING ` + imageColumns
+	productImage, err := scanProductImage(db.DB.QueryRow(query, id, storageKey, thumbnailKey, contentType, bounds.Dx(), bounds.Dy()))
+	if err != nil {
+		deleteBlobs(c, storageKey, thumbnailKey)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save image"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Image uploaded successfully", "image": productImage})
+}
+
+func GetProductImages(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	images, err := loadProductImages([]int{id})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve images"})
+		return
+	}
+
+	productImages := images[id]
+	if productImages == nil {
+		productImages = []models.ProductImage{}
+	}
+	c.JSON(http.StatusOK, gin.H{"images": productImages})
+}
+
+// ReorderProductImages sets the image order to the given list of image IDs,
+// which must contain every image of the product exactly once.
+func ReorderProductImages(c *gin.Context) {
+	// Authentication
+	username, exists := c.Get("username")
+	if !exists || username != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	var input models.ImageOrderInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Start transaction
+	tx, err := db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+
+	rows, err := tx.Query(`SELECT id FROM product_images WHERE product_id = $1 FOR UPDATE`, id)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve images"})
+		return
+	}
+	current := map[int]bool{}
+	for rows.Next() {
+		var imageID int
+		if err := rows.Scan(&imageID); err != nil {
+			rows.Close()
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan image"})
+			return
+		}
+		current[imageID] = true
+	}
+	rows.Close()
+
+	seen := map[int]bool{}
+	for _, imageID := range input.ImageIDs {
+		if !current[imageID] || seen[imageID] {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "image_ids must list every image of the product exactly once"})
+			return
+		}
+		seen[imageID] = true
+	}
+	if len(seen) != len(current) {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "image_ids must list every image of the product exactly once"})
+		return
+	}
+
+	for position, imageID := range input.ImageIDs {
+		_, err = tx.Exec(`UPDATE product_images SET position = $1 WHERE id = $2`, position, imageID)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reorder images"})
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	images, err := loadProductImages([]int{id})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve images"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Images reordered successfully", "images": images[id]})
+}
+
+func DeleteProductImage(c *gin.Context) {
+	// Authentication
+	username, exists := c.Get("username")
+	if !exists || username != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+	imageID, err := strconv.Atoi(c.Param("image_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image ID"})
+		return
+	}
+
+	// Start transaction
+	tx, err := db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+
+	var storageKey, thumbnailKey string
+	var position int
+	query := `DELETE FROM product_images WHERE id = $1 AND product_id = $2 RETURNING storage_key, thumbnail_key, position`
+	err = tx.QueryRow(query, imageID, id).Scan(&storageKey, &thumbnailKey, &position)
+	if err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete image"})
+		}
+		return
+	}
+
+	// Close the gap left in the ordering
+	_, err = tx.Exec(`UPDATE product_images SET position = position - 1 WHERE product_id = $1 AND position > $2`, id, position)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete image"})
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	deleteBlobs(c, storageKey, thumbnailKey)
+	c.JSON(http.StatusOK, gin.H{"message": "Image deleted successfully"})
+}
+
+const imageColumns = `id, product_id, position, storage_key, thumbnail_key, content_type, width, height, created_at`
+
+func scanProductImage(row rowScanner) (models.ProductImage, error) {
+	var productImage models.ProductImage
+	var storageKey, thumbnailKey string
+	err := row.Scan(&productImage.ID, &productImage.ProductID, &productImage.Position, &storageKey, &thumbnailKey,
+		&productImage.ContentType, &productImage.Width, &productImage.Height, &productImage.CreatedAt)
+	if err != nil {
+		return productImage, err
+	}
+	productImage.URL = storage.Store.URL(storageKey)
+	productImage.ThumbnailURL = storage.Store.URL(thumbnailKey)
+	return productImage, nil
+}
+
+// loadProductImages fetches the images of several products in one query,
+// keyed by product ID and sorted by position
+func loadProductImages(productIDs []int) (map[int][]models.ProductImage, error) {
+	query := `SELECT ` + imageColumns + ` FROM product_images WHERE product_id = ANY($1) ORDER BY product_id, position`
+	rows, err := db.DB.Query(query, pq.Array(productIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	images := map[int][]models.ProductImage{}
+	for rows.Next() {
+		productImage, err := scanProductImage(rows)
+		if err != nil {
+			return nil, err
+		}
+		images[productImage.ProductID] = append(images[productImage.ProductID], productImage)
+	}
+	return images, rows.Err()
+}
+
+// deleteBlobs removes stored objects; failures only leave orphaned files behind
+func deleteBlobs(c *gin.Context, keys ...string) {
+	for _, key := range keys {
+		if err := storage.Store.Delete(c.Request.Context(), key); err != nil {
+			log.Printf("Failed to delete blob %s: %v", key, err)
+		}
+	}
+}
+
+func randomName() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"net/http"
+	"product-service/db"
+	"product-service/inventory"
+	"product-service/models"
+	"product-service/rabbitmq"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func AdjustStock(c *gin.Context) {
+	// Authentication
+	username, exists := c.Get("username")
+	if !exists || username != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	var input models.StockAdjustmentInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Order and cancellation movements are booked from events only
+	switch input.Reason {
+	case models.MovementReasonRestock:
+		if input.Change <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Restock change must be positive"})
+			return
+		}
+	case models.MovementReasonAdjustment:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reason must be one of: restock, adjustment"})
+		return
+	}
+
+	movement, err := inventory.ApplyMovement(models.StockMovement{
+		ProductID: id,
+		Change:    input.Change,
+		Reason:    input.Reason,
+		Actor:     username.(string),
+		Note:      input.Note,
+	})
+	if err != nil {
+		switch err {
+		case inventory.ErrProductNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		case inventory.ErrInsufficientStock:
+			c.JSON(http.StatusConflict, gin.H{"error": "Adjustment would make inventory negative"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to adjust stock"})
+		}
+		return
+	}
+
+	rabbitmq.EmitStockChanged(movement)
+	c.JSON(http.StatusOK, gin.H{"message": "Stock adjusted successfully", "movement": movement})
+}
+
+func GetStockHistory(c *gin.Context) {
+	// Authentication
+	username, exists := c.Get("username")
+	if !exists || username != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	movements, err := inventory.History(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve stock history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"product_id": id, "movements": movements})
+}
+
+func GetLowStockProducts(c *gin.Context) {
+	// Authentication
+	username, exists := c.Get("username")
+	if !exists || username != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	query := `SELECT ` + productColumns + ` FROM products
+	WHERE archived_at IS NULL AND (inventory <= 0 OR inventory <= reorder_threshold)
+	ORDER BY inventory - reorder_threshold, id`
+	rows, err := db.DB.Query(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve products"})
+		return
+	}
+	defer rows.Close()
+
+	products := []models.Product{}
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan product"})
+			return
+		}
+		products = append(products, product)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"products": products})
+}
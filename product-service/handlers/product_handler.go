@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"net/http"
 	"product-service/db"
+	"product-service/inventory"
+	"product-service/metrics"
 	"product-service/models"
 	"product-service/rabbitmq"
 	"product-service/utils"
@@ -13,6 +15,7 @@ import (
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
 )
 
 func Authenticate(c *gin.Context) {
@@ -46,9 +49,38 @@ func Authenticate(c *gin.Context) {
 	c.Next()
 }
 
+// productColumns lists the products columns in the order scanProduct reads them
+const productColumns = `id, name, description, price, category, tax_class, weight_grams, inventory, reorder_threshold, version, created_at, archived_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanProduct(row rowScanner) (models.Product, error) {
+	var product models.Product
+	var archivedAt sql.NullString
+	err := row.Scan(&product.ID, &product.Name, &product.Description, &product.Price, &product.Category, &product.TaxClass, &product.WeightGrams, &product.Inventory, &product.ReorderThreshold, &product.Version, &product.CreatedAt, &archivedAt)
+	if archivedAt.Valid {
+		product.ArchivedAt = &archivedAt.String
+	}
+	return product, err
+}
+
 func GetAllProducts(c *gin.Context) {
-	query := `SELECT id, name, description, price, inventory, created_at FROM products`
-	rows, err := db.DB.Query(query)
+	// Archived products are hidden from listings, but ?ids=1,2,3 fetches
+	// exactly those products so orders can still show archived ones
+	query := `SELECT ` + productColumns + ` FROM products WHERE archived_at IS NULL`
+	args := []interface{}{}
+	if idsParam := c.Query("ids"); idsParam != "" {
+		ids, err := parseIDList(idsParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product IDs"})
+			return
+		}
+		query = `SELECT ` + productColumns + ` FROM products WHERE id = ANY($1)`
+		args = append(args, pq.Array(ids))
+	}
+	rows, err := db.DB.Query(query, args...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve products"})
 		return
@@ -57,8 +89,7 @@ func GetAllProducts(c *gin.Context) {
 
 	products := []models.Product{}
 	for rows.Next() {
-		var product models.Product
-		err := rows.Scan(&product.ID, &product.Name, &product.Description, &product.Price, &product.Inventory, &product.CreatedAt)
+		product, err := scanProduct(rows)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan product"})
 			return
@@ -66,9 +97,36 @@ func GetAllProducts(c *gin.Context) {
 		products = append(products, product)
 	}
 
+	// Attach images with a single batched query
+	productIDs := make([]int, len(products))
+	for i, product := range products {
+		productIDs[i] = product.ID
+	}
+	images, err := loadProductImages(productIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve product images"})
+		return
+	}
+	for i := range products {
+		products[i].Images = images[products[i].ID]
+	}
+
 	c.JSON(http.StatusOK, gin.H{"products": products})
 }
 
+// parseIDList parses a comma-separated list of IDs
+func parseIDList(param string) ([]int, error) {
+	ids := []int{}
+	for _, part := range strings.Split(param, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
 func GetProductByID(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.Atoi(idParam)
@@ -77,9 +135,8 @@ func GetProductByID(c *gin.Context) {
 		return
 	}
 
-	query := `SELECT id, name, description, price, inventory, created_at FROM products WHERE id = $1`
-	var product models.Product
-	err = db.DB.QueryRow(query, id).Scan(&product.ID, &product.Name, &product.Description, &product.Price, &product.Inventory, &product.CreatedAt)
+	query := `SELECT ` + productColumns + ` FROM products WHERE id = $1`
+	product, err := scanProduct(db.DB.QueryRow(query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
@@ -89,6 +146,20 @@ func GetProductByID(c *gin.Context) {
 		return
 	}
 
+	images, err := loadProductImages([]int{product.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve product images"})
+		return
+	}
+	product.Images = images[product.ID]
+
+	// Archived products still resolve so historical orders can show them
+	if product.ArchivedAt != nil {
+		c.JSON(http.StatusGone, gin.H{"error": "Product has been archived", "product": product})
+		return
+	}
+
+	c.Header("ETag", productETag(product.Version))
 	c.JSON(http.StatusOK, gin.H{"product": product})
 }
 
@@ -106,14 +177,52 @@ func CreateProduct(c *gin.Context) {
 		return
 	}
 
-	query := `INSERT INTO products (name, description, price, inventory) VALUES ($1, $2, $3, $4) RETURNING id, created_at`
-	err := db.DB.QueryRow(query, input.Name, input.Description, input.Price, input.Inventory).Scan(&input.ID, &input.CreatedAt)
+	if input.Inventory < 0 || input.ReorderThreshold < 0 || input.WeightGrams < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Inventory, reorder threshold and weight cannot be negative"})
+		return
+	}
+	if input.TaxClass == "" {
+		input.TaxClass = models.TaxClassStandard
+	}
+
+	// Start transaction
+	tx, err := db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+
+	// The product starts empty and its opening stock is booked through the ledger
+	query := `INSERT INTO products (name, description, price, category, tax_class, weight_grams, inventory, reorder_threshold)
+	VALUES ($1, $2, $3, $4, $5, $6, 0, $7) RETURNING id, version, created_at`
+	err = tx.QueryRow(query, input.Name, input.Description, input.Price, input.Category, input.TaxClass, input.WeightGrams, input.ReorderThreshold).Scan(&input.ID, &input.Version, &input.CreatedAt)
 	if err != nil {
+		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create product"})
 		return
 	}
 
+	movement, err := inventory.RecordMovement(tx, models.StockMovement{
+		ProductID: input.ID,
+		Change:    input.Inventory,
+		Reason:    models.MovementReasonInitial,
+		Actor:     username.(string),
+	})
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record initial stock"})
+		return
+	}
+	input.Inventory = movement.ResultingInventory
+
+	err = tx.Commit()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
 	rabbitmq.EmitProductCreated(input)
+	metrics.ProductStock.WithLabelValues(strconv.Itoa(input.ID)).Set(float64(input.Inventory))
 	c.JSON(http.StatusOK, gin.H{"message": "Product created successfully", "product": input})
 }
 
@@ -138,18 +247,160 @@ func UpdateProduct(c *gin.Context) {
 		return
 	}
 
-	query := `UPDATE products SET name = $1, description = $2, price = $3, inventory = $4 WHERE id = $5`
-	_, err = db.DB.Exec(query, input.Name, input.Description, input.Price, input.Inventory, id)
+	// A full update replaces every catalog field. Inventory is owned by the
+	// stock ledger and changed through stock adjustments.
+	updateProduct(c, id, models.ProductPatch{
+		Name:             &input.Name,
+		Description:      &input.Description,
+		Price:            &input.Price,
+		Category:         &input.Category,
+		TaxClass:         &input.TaxClass,
+		WeightGrams:      &input.WeightGrams,
+		ReorderThreshold: &input.ReorderThreshold,
+	})
+}
+
+func PatchProduct(c *gin.Context) {
+	// Authentication
+	username, exists := c.Get("username")
+	if !exists || username != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	var input models.ProductPatch
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Inventory != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Inventory must be changed through stock adjustments"})
 		return
 	}
 
-	input.ID, _ = strconv.Atoi(idParam)
-	rabbitmq.EmitProductUpdated(input)
-	c.JSON(http.StatusOK, gin.H{"message": "Product updated successfully"})
+	updateProduct(c, id, input)
 }
 
+// updateProduct applies the non-nil fields of patch using optimistic
+// concurrency on the product version. The expected version comes from the
+// If-Match header (412 on mismatch) or the patch body (409 on mismatch); a
+// concurrent write between our read and update also yields 409.
+func updateProduct(c *gin.Context, id int, patch models.ProductPatch) {
+	if (patch.Price != nil && *patch.Price < 0) || (patch.ReorderThreshold != nil && *patch.ReorderThreshold < 0) ||
+		(patch.WeightGrams != nil && *patch.WeightGrams < 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Price, reorder threshold and weight cannot be negative"})
+		return
+	}
+	if patch.TaxClass != nil && *patch.TaxClass == "" {
+		patch.TaxClass = nil
+	}
+
+	query := `SELECT ` + productColumns + ` FROM products WHERE id = $1`
+	current, err := scanProduct(db.DB.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve product"})
+		}
+		return
+	}
+
+	if current.ArchivedAt != nil {
+		c.JSON(http.StatusGone, gin.H{"error": "Product has been archived"})
+		return
+	}
+
+	if ifMatch := c.GetHeader("If-Match"); ifMatch != "" && !etagMatches(ifMatch, current.Version) {
+		c.Header("ETag", productETag(current.Version))
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Product has been modified", "version": current.Version})
+		return
+	}
+	if patch.Version != nil && *patch.Version != current.Version {
+		c.Header("ETag", productETag(current.Version))
+		c.JSON(http.StatusConflict, gin.H{"error": "Product has been modified", "version": current.Version})
+		return
+	}
+
+	updated := current
+	event := models.ProductUpdatedEvent{ID: current.ID}
+	changed := false
+	if patch.Name != nil && *patch.Name != current.Name {
+		updated.Name, event.Name, changed = *patch.Name, patch.Name, true
+	}
+	if patch.Description != nil && *patch.Description != current.Description {
+		updated.Description, event.Description, changed = *patch.Description, patch.Description, true
+	}
+	if patch.Price != nil && *patch.Price != current.Price {
+		updated.Price, event.Price, changed = *patch.Price, patch.Price, true
+	}
+	if patch.Category != nil && *patch.Category != current.Category {
+		updated.Category, event.Category, changed = *patch.Category, patch.Category, true
+	}
+	if patch.TaxClass != nil && *patch.TaxClass != current.TaxClass {
+		updated.TaxClass, event.TaxClass, changed = *patch.TaxClass, patch.TaxClass, true
+	}
+	if patch.WeightGrams != nil && *patch.WeightGrams != current.WeightGrams {
+		updated.WeightGrams, event.WeightGrams, changed = *patch.WeightGrams, patch.WeightGrams, true
+	}
+	if patch.ReorderThreshold != nil && *patch.ReorderThreshold != current.ReorderThreshold {
+		updated.ReorderThreshold, event.ReorderThreshold, changed = *patch.ReorderThreshold, patch.ReorderThreshold, true
+	}
+
+	if !changed {
+		c.Header("ETag", productETag(current.Version))
+		c.JSON(http.StatusOK, gin.H{"message": "Product unchanged", "product": current})
+		return
+	}
+
+	query = `UPDATE products SET name = $1, description = $2, price = $3, category = $4, tax_class = $5, weight_grams = $6,
+	reorder_threshold = $7, version = version + 1
+	WHERE id = $8 AND version = $9 RETURNING ` + productColumns
+	product, err := scanProduct(db.DB.QueryRow(query, updated.Name, updated.Description, updated.Price, updated.Category,
+		updated.TaxClass, updated.WeightGrams, updated.ReorderThreshold, id, current.Version))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusConflict, gin.H{"error": "Product was modified concurrently, please retry"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product"})
+		}
+		return
+	}
+
+	event.Version = product.Version
+	rabbitmq.EmitProductUpdated(event)
+	if event.ReorderThreshold != nil {
+		rabbitmq.EmitThresholdChanged(product, current.ReorderThreshold)
+	}
+	c.Header("ETag", productETag(product.Version))
+	c.JSON(http.StatusOK, gin.H{"message": "Product updated successfully", "product": product})
+}
+
+func productETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// etagMatches reports whether an If-Match header value matches the version
+func etagMatches(header string, version int) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == productETag(version) {
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteProduct archives the product instead of removing the row, so orders
+// that reference it keep resolving. Archived products can no longer be ordered.
 func DeleteProduct(c *gin.Context) {
 	// Authentication
 	username, exists := c.Get("username")
@@ -165,13 +416,65 @@ func DeleteProduct(c *gin.Context) {
 		return
 	}
 
-	query := `DELETE FROM products WHERE id = $1`
-	_, err = db.DB.Exec(query, id)
+	query := `UPDATE products SET archived_at = CURRENT_TIMESTAMP WHERE id = $1 AND archived_at IS NULL RETURNING ` + productColumns
+	product, err := scanProduct(db.DB.QueryRow(query, id))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete product"})
+		if err == sql.ErrNoRows {
+			respondArchiveState(c, id, http.StatusGone, "Product is already archived")
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete product"})
+		}
 		return
 	}
 
 	rabbitmq.EmitProductDeleted(id)
-	c.JSON(http.StatusOK, gin.H{"message": "Product deleted successfully"})
+	metrics.ProductStock.DeleteLabelValues(idParam)
+	c.JSON(http.StatusOK, gin.H{"message": "Product archived successfully", "product": product})
+}
+
+func RestoreProduct(c *gin.Context) {
+	// Authentication
+	username, exists := c.Get("username")
+	if !exists || username != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	idParam := c.Param("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+
+	query := `UPDATE products SET archived_at = NULL WHERE id = $1 AND archived_at IS NOT NULL RETURNING ` + productColumns
+	product, err := scanProduct(db.DB.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondArchiveState(c, id, http.StatusConflict, "Product is not archived")
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore product"})
+		}
+		return
+	}
+
+	rabbitmq.EmitProductRestored(product)
+	metrics.ProductStock.WithLabelValues(idParam).Set(float64(product.Inventory))
+	c.JSON(http.StatusOK, gin.H{"message": "Product restored successfully", "product": product})
+}
+
+// respondArchiveState answers an archive or restore that matched no row:
+// 404 when the product does not exist, otherwise the given status.
+func respondArchiveState(c *gin.Context, id int, status int, message string) {
+	var exists bool
+	err := db.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM products WHERE id = $1)`, id).Scan(&exists)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve product"})
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+	c.JSON(status, gin.H{"error": message})
 }
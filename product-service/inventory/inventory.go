@@ -0,0 +1,178 @@
+package inventory
+
+import (
+	"database/sql"
+	"errors"
+	"product-service/db"
+	"product-service/models"
+)
+
+var (
+	ErrProductNotFound   = errors.New("product not found")
+	ErrInsufficientStock = errors.New("insufficient stock")
+)
+
+// ApplyMovement records a stock movement and updates the product's current
+// inventory level in a single transaction.
+func ApplyMovement(movement models.StockMovement) (models.StockMovement, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return movement, err
+	}
+
+	movement, err = RecordMovement(tx, movement)
+	if err != nil {
+		tx.Rollback()
+		return movement, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return movement, err
+	}
+	return movement, nil
+}
+
+// RecordMovement appends a movement to the ledger within an existing
+// transaction. The products.inventory column is only ever changed here, so it
+// always matches the sum of the product's movements. Orders cannot take stock
+// of archived products, which are not found for them.
+func RecordMovement(tx *sql.Tx, movement models.StockMovement) (models.StockMovement, error) {
+	ordered := movement.Reason == models.MovementReasonOrder
+	query := `UPDATE products SET inventory = inventory + $1
+	WHERE id = $2 AND inventory + $1 >= 0 AND (archived_at IS NULL OR NOT $3)
+	RETURNING inventory, reorder_threshold`
+	err := tx.QueryRow(query, movement.Change, movement.ProductID, ordered).Scan(&movement.ResultingInventory, &movement.ReorderThreshold)
+	if err == sql.ErrNoRows {
+		var archived bool
+		err = tx.QueryRow(`SELECT archived_at IS NOT NULL FROM products WHERE id = $1`, movement.ProductID).Scan(&archived)
+		if err == sql.ErrNoRows || (archived && ordered) {
+			return movement, ErrProductNotFound
+		}
+		if err != nil {
+			return movement, err
+		}
+		return movement, ErrInsufficientStock
+	}
+	if err != nil {
+		return movement, err
+	}
+
+	query = `INSERT INTO stock_movements (product_id, change, reason, actor, order_id, return_id, note, resulting_inventory)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, created_at`
+	err = tx.QueryRow(query, movement.ProductID, movement.Change, movement.Reason, movement.Actor,
+		movement.OrderID, movement.ReturnID, movement.Note, movement.ResultingInventory).Scan(&movement.ID, &movement.CreatedAt)
+	if err != nil {
+		return movement, err
+	}
+	return movement, nil
+}
+
+// ReturnOrderStock books back the stock an order took from a product. The
+// quantity comes from the ledger rather than the cancellation event, so stock
+// that was never decremented is not returned and a redelivered event books
+// nothing; returned is false in both cases.
+func ReturnOrderStock(orderID, productID int, actor, note string) (movement models.StockMovement, returned bool, err error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return movement, false, err
+	}
+	defer tx.Rollback()
+
+	// Lock the product so concurrent returns for the order see each other
+	var id int
+	err = tx.QueryRow(`SELECT id FROM products WHERE id = $1 FOR UPDATE`, productID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return movement, false, ErrProductNotFound
+	}
+	if err != nil {
+		return movement, false, err
+	}
+
+	var outstanding int
+	query := `SELECT COALESCE(-SUM(change), 0) FROM stock_movements WHERE order_id = $1 AND product_id = $2 AND reason IN ($3, $4)`
+	err = tx.QueryRow(query, orderID, productID, models.MovementReasonOrder, models.MovementReasonCancellationReturn).Scan(&outstanding)
+	if err != nil {
+		return movement, false, err
+	}
+	if outstanding <= 0 {
+		return movement, false, nil
+	}
+
+	movement, err = RecordMovement(tx, models.StockMovement{
+		ProductID: productID,
+		Change:    outstanding,
+		Reason:    models.MovementReasonCancellationReturn,
+		Actor:     actor,
+		OrderID:   &orderID,
+		Note:      note,
+	})
+	if err != nil {
+		return movement, false, err
+	}
+	return movement, true, tx.Commit()
+}
+
+// RestockReturn puts the units of an order return back into stock. A return
+// books at most one movement per product, so a redelivered event books
+// nothing and returned is false.
+func RestockReturn(returnID, orderID, productID, quantity int, actor string) (movement models.StockMovement, returned bool, err error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return movement, false, err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	query := `SELECT EXISTS (SELECT 1 FROM stock_movements WHERE return_id = $1 AND product_id = $2)`
+	if err := tx.QueryRow(query, returnID, productID).Scan(&exists); err != nil {
+		return movement, false, err
+	}
+	if exists {
+		return movement, false, nil
+	}
+
+	movement, err = RecordMovement(tx, models.StockMovement{
+		ProductID: productID,
+		Change:    quantity,
+		Reason:    models.MovementReasonReturnRestock,
+		Actor:     actor,
+		OrderID:   &orderID,
+		ReturnID:  &returnID,
+	})
+	if err != nil {
+		return movement, false, err
+	}
+	return movement, true, tx.Commit()
+}
+
+// History returns the movements of a product, newest first.
+func History(productID int) ([]models.StockMovement, error) {
+	query := `SELECT id, product_id, change, reason, actor, order_id, return_id, note, resulting_inventory, created_at
+	FROM stock_movements WHERE product_id = $1 ORDER BY id DESC`
+	rows, err := db.DB.Query(query, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	movements := []models.StockMovement{}
+	for rows.Next() {
+		var movement models.StockMovement
+		var orderID, returnID sql.NullInt64
+		err := rows.Scan(&movement.ID, &movement.ProductID, &movement.Change, &movement.Reason, &movement.Actor,
+			&orderID, &returnID, &movement.Note, &movement.ResultingInventory, &movement.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if orderID.Valid {
+			id := int(orderID.Int64)
+			movement.OrderID = &id
+		}
+		if returnID.Valid {
+			id := int(returnID.Int64)
+			movement.ReturnID = &id
+		}
+		movements = append(movements, movement)
+	}
+	return movements, rows.Err()
+}
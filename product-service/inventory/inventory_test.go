@@ -0,0 +1,199 @@
+package inventory
+
+import (
+	"database/sql"
+	"errors"
+	"product-service/db"
+	"product-service/models"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+const (
+	updateInventory = `UPDATE products SET inventory = inventory \+ \$1`
+	selectArchived  = `SELECT archived_at IS NOT NULL FROM products`
+	insertMovement  = `INSERT INTO stock_movements`
+	lockProduct     = `SELECT id FROM products WHERE id = \$1 FOR UPDATE`
+	sumOrderStock   = `SELECT COALESCE\(-SUM\(change\), 0\) FROM stock_movements`
+)
+
+// Helper function to point the package at a mocked database
+func mockDB(t *testing.T) sqlmock.Sqlmock {
+	mockedDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	previous := db.DB
+	db.DB = mockedDB
+	t.Cleanup(func() {
+		db.DB = previous
+		mockedDB.Close()
+	})
+	return mock
+}
+
+func TestApplyMovement(t *testing.T) {
+	tests := []struct {
+		name      string
+		movement  models.StockMovement
+		expect    func(mock sqlmock.Sqlmock)
+		err       error
+		inventory int
+	}{
+		{
+			name:     "restock is booked",
+			movement: models.StockMovement{ProductID: 1, Change: 10, Reason: models.MovementReasonRestock, Actor: "admin"},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(updateInventory).WithArgs(10, 1, false).
+					WillReturnRows(sqlmock.NewRows([]string{"inventory", "reorder_threshold"}).AddRow(15, 5))
+				mock.ExpectQuery(insertMovement).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(7, "2026-10-19T10:00:00Z"))
+				mock.ExpectCommit()
+			},
+			inventory: 15,
+		},
+		{
+			name:     "order takes the last units",
+			movement: models.StockMovement{ProductID: 1, Change: -5, Reason: models.MovementReasonOrder, Actor: "order-service"},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(updateInventory).WithArgs(-5, 1, true).
+					WillReturnRows(sqlmock.NewRows([]string{"inventory", "reorder_threshold"}).AddRow(0, 5))
+				mock.ExpectQuery(insertMovement).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(8, "2026-10-19T10:00:00Z"))
+				mock.ExpectCommit()
+			},
+			inventory: 0,
+		},
+		{
+			name:     "stock cannot go negative",
+			movement: models.StockMovement{ProductID: 1, Change: -6, Reason: models.MovementReasonOrder, Actor: "order-service"},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(updateInventory).WithArgs(-6, 1, true).WillReturnError(sql.ErrNoRows)
+				mock.ExpectQuery(selectArchived).WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"archived"}).AddRow(false))
+				mock.ExpectRollback()
+			},
+			err: ErrInsufficientStock,
+		},
+		{
+			name:     "adjustment cannot make stock negative",
+			movement: models.StockMovement{ProductID: 1, Change: -20, Reason: models.MovementReasonAdjustment, Actor: "admin"},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(updateInventory).WithArgs(-20, 1, false).WillReturnError(sql.ErrNoRows)
+				mock.ExpectQuery(selectArchived).WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"archived"}).AddRow(true))
+				mock.ExpectRollback()
+			},
+			err: ErrInsufficientStock,
+		},
+		{
+			name:     "archived product cannot be ordered",
+			movement: models.StockMovement{ProductID: 1, Change: -1, Reason: models.MovementReasonOrder, Actor: "order-service"},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(updateInventory).WithArgs(-1, 1, true).WillReturnError(sql.ErrNoRows)
+				mock.ExpectQuery(selectArchived).WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"archived"}).AddRow(true))
+				mock.ExpectRollback()
+			},
+			err: ErrProductNotFound,
+		},
+		{
+			name:     "missing product",
+			movement: models.StockMovement{ProductID: 99, Change: 1, Reason: models.MovementReasonRestock, Actor: "admin"},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(updateInventory).WithArgs(1, 99, false).WillReturnError(sql.ErrNoRows)
+				mock.ExpectQuery(selectArchived).WithArgs(99).WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
+			},
+			err: ErrProductNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockDB(t)
+			mock.ExpectBegin()
+			tt.expect(mock)
+
+			movement, err := ApplyMovement(tt.movement)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+			if err == nil && movement.ResultingInventory != tt.inventory {
+				t.Errorf("resulting inventory = %d, want %d", movement.ResultingInventory, tt.inventory)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestReturnOrderStock(t *testing.T) {
+	tests := []struct {
+		name     string
+		expect   func(mock sqlmock.Sqlmock)
+		err      error
+		returned bool
+		change   int
+	}{
+		{
+			name: "outstanding stock is booked back",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(lockProduct).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				mock.ExpectQuery(sumOrderStock).
+					WithArgs(42, 1, models.MovementReasonOrder, models.MovementReasonCancellationReturn).
+					WillReturnRows(sqlmock.NewRows([]string{"outstanding"}).AddRow(3))
+				mock.ExpectQuery(updateInventory).WithArgs(3, 1, false).
+					WillReturnRows(sqlmock.NewRows([]string{"inventory", "reorder_threshold"}).AddRow(8, 5))
+				mock.ExpectQuery(insertMovement).
+					WithArgs(1, 3, models.MovementReasonCancellationReturn, "order-service", 42, nil, "Order cancelled", 8).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(9, "2026-10-19T10:00:00Z"))
+				mock.ExpectCommit()
+			},
+			returned: true,
+			change:   3,
+		},
+		{
+			name: "stock already returned is not booked again",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(lockProduct).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				mock.ExpectQuery(sumOrderStock).
+					WithArgs(42, 1, models.MovementReasonOrder, models.MovementReasonCancellationReturn).
+					WillReturnRows(sqlmock.NewRows([]string{"outstanding"}).AddRow(0))
+				mock.ExpectRollback()
+			},
+		},
+		{
+			name: "missing product",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(lockProduct).WithArgs(1).WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
+			},
+			err: ErrProductNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockDB(t)
+			mock.ExpectBegin()
+			tt.expect(mock)
+
+			movement, returned, err := ReturnOrderStock(42, 1, "order-service", "Order cancelled")
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+			if returned != tt.returned {
+				t.Errorf("returned = %v, want %v", returned, tt.returned)
+			}
+			if returned && movement.Change != tt.change {
+				t.Errorf("change = %d, want %d", movement.Change, tt.change)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
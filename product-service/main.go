@@ -3,7 +3,10 @@ package main
 import (
 	"product-service/db"
 	"product-service/handlers"
+	"product-service/metrics"
 	"product-service/rabbitmq"
+	"product-service/storage"
+	"shared/idempotency"
 
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus"
@@ -15,12 +18,23 @@ func main() {
 	db.Init()
 	defer db.DB.Close()
 
+	// Initialize idempotency keys and purge expired ones
+	idempotency.Init(db.DB)
+
+	// Initialize stock metrics
+	metrics.Init()
+
+	// Initialize blob storage for product images
+	storage.Init()
+
 	// Initialize RabbitMQ
 	rabbitmq.Init()
 	defer rabbitmq.Close()
 
 	// Start listening for events
 	rabbitmq.ListenForOrderPlacedEvents()
+	rabbitmq.ListenForOrderCancelledEvents()
+	rabbitmq.ListenForReturnInspectedEvents()
 
 	// Set up router
 	r := gin.Default()
@@ -47,13 +61,27 @@ func main() {
 	// Public routes
 	r.GET("/products", handlers.GetAllProducts)
 	r.GET("/products/:id", handlers.GetProductByID)
+	r.GET("/products/:id/images", handlers.GetProductImages)
+
+	// Serve uploaded media when images are stored on the local filesystem
+	if local, ok := storage.Store.(*storage.LocalStore); ok {
+		r.Static("/media", local.Dir)
+	}
 
 	// Protected routes
 	authorized := r.Group("/", handlers.Authenticate)
 	{
-		authorized.POST("/products", handlers.CreateProduct)
-		authorized.PUT("/products/:id", handlers.UpdateProduct)
+		authorized.POST("/products", idempotency.Middleware, handlers.CreateProduct)
+		authorized.PUT("/products/:id", idempotency.Middleware, handlers.UpdateProduct)
+		authorized.PATCH("/products/:id", idempotency.Middleware, handlers.PatchProduct)
 		authorized.DELETE("/products/:id", handlers.DeleteProduct)
+		authorized.POST("/products/:id/restore", handlers.RestoreProduct)
+		authorized.POST("/products/:id/stock-adjustments", idempotency.Middleware, handlers.AdjustStock)
+		authorized.GET("/products/:id/stock-history", handlers.GetStockHistory)
+		authorized.GET("/admin/products/low-stock", handlers.GetLowStockProducts)
+		authorized.POST("/products/:id/images", handlers.UploadProductImage)
+		authorized.PUT("/products/:id/images/order", handlers.ReorderProductImages)
+		authorized.DELETE("/products/:id/images/:image_id", handlers.DeleteProductImage)
 	}
 
 	// Start server
@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"log"
+	"product-service/db"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var ProductStock = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "product_service_product_stock",
+		Help: "Current inventory level per product",
+	},
+	[]string{"product_id"},
+)
+
+func Init() {
+	prometheus.MustRegister(ProductStock)
+
+	// Seed the gauge with the current stock levels
+	rows, err := db.DB.Query(`SELECT id, inventory FROM products`)
+	if err != nil {
+		log.Printf("Failed to load stock levels for metrics: %v", err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, inventory int
+		if err := rows.Scan(&id, &inventory); err != nil {
+			log.Printf("Failed to scan stock level: %v", err)
+			return
+		}
+		ProductStock.WithLabelValues(strconv.Itoa(id)).Set(float64(inventory))
+	}
+}
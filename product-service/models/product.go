@@ -1,12 +1,95 @@
 package models
 
+// TaxClassStandard is the tax class of products that do not name one
+const TaxClassStandard = "standard"
+
 type Product struct {
-	ID          int     `json:"id"`
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
-	Inventory   int     `json:"inventory"`
-	CreatedAt   string  `json:"created_at"`
+	ID               int            `json:"id"`
+	Name             string         `json:"name"`
+	Description      string         `json:"description"`
+	Price            float64        `json:"price"`
+	Category         string         `json:"category"`
+	TaxClass         string         `json:"tax_class"`
+	WeightGrams      int            `json:"weight_grams"`
+	Inventory        int            `json:"inventory"`
+	ReorderThreshold int            `json:"reorder_threshold"`
+	Version          int            `json:"version"`
+	CreatedAt        string         `json:"created_at"`
+	ArchivedAt       *string        `json:"archived_at,omitempty"`
+	Images           []ProductImage `json:"images,omitempty"`
+}
+
+type ProductImage struct {
+	ID           int    `json:"id"`
+	ProductID    int    `json:"product_id"`
+	Position     int    `json:"position"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	ContentType  string `json:"content_type"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	CreatedAt    string `json:"created_at"`
+}
+
+type ImageOrderInput struct {
+	ImageIDs []int `json:"image_ids" binding:"required"`
+}
+
+// ProductPatch holds the fields of a partial update; nil fields are left unchanged
+type ProductPatch struct {
+	Name             *string  `json:"name"`
+	Description      *string  `json:"description"`
+	Price            *float64 `json:"price"`
+	Category         *string  `json:"category"`
+	TaxClass         *string  `json:"tax_class"`
+	WeightGrams      *int     `json:"weight_grams"`
+	ReorderThreshold *int     `json:"reorder_threshold"`
+	Inventory        *int     `json:"inventory"`
+	Version          *int     `json:"version"`
+}
+
+// ProductUpdatedEvent carries only the fields that changed plus the new version
+type ProductUpdatedEvent struct {
+	ID               int      `json:"id"`
+	Version          int      `json:"version"`
+	Name             *string  `json:"name,omitempty"`
+	Description      *string  `json:"description,omitempty"`
+	Price            *float64 `json:"price,omitempty"`
+	Category         *string  `json:"category,omitempty"`
+	TaxClass         *string  `json:"tax_class,omitempty"`
+	WeightGrams      *int     `json:"weight_grams,omitempty"`
+	ReorderThreshold *int     `json:"reorder_threshold,omitempty"`
+}
+
+// Reasons recorded on stock movements
+const (
+	MovementReasonInitial            = "initial"
+	MovementReasonOrder              = "order"
+	MovementReasonRestock            = "restock"
+	MovementReasonAdjustment         = "adjustment"
+	MovementReasonCancellationReturn = "cancellation_return"
+	MovementReasonReturnRestock      = "return_restock"
+)
+
+type StockMovement struct {
+	ID                 int    `json:"id"`
+	ProductID          int    `json:"product_id"`
+	Change             int    `json:"change"`
+	Reason             string `json:"reason"`
+	Actor              string `json:"actor"`
+	OrderID            *int   `json:"order_id,omitempty"`
+	ReturnID           *int   `json:"return_id,omitempty"`
+	Note               string `json:"note"`
+	ResultingInventory int    `json:"resulting_inventory"`
+	CreatedAt          string `json:"created_at"`
+	// ReorderThreshold is the product's threshold when the movement was booked
+	ReorderThreshold int `json:"-"`
+}
+
+type StockAdjustmentInput struct {
+	Change int    `json:"change" binding:"required"`
+	Reason string `json:"reason" binding:"required"`
+	Note   string `json:"note"`
 }
 
 type InventoryUpdateEvent struct {
@@ -14,11 +97,63 @@ type InventoryUpdateEvent struct {
 	NewInventory int `json:"new_inventory"`
 }
 
+type StockAlertEvent struct {
+	ProductID        int `json:"product_id"`
+	Inventory        int `json:"inventory"`
+	ReorderThreshold int `json:"reorder_threshold"`
+}
+
 type OrderPlacedEvent struct {
 	OrderID int         `json:"order_id"`
+	UserID  int         `json:"user_id"`
 	Items   []OrderItem `json:"items"`
 }
 
+type OrderCancelledEvent struct {
+	OrderID int    `json:"order_id"`
+	UserID  int    `json:"user_id"`
+	Reason  string `json:"reason"`
+	// CancelledBy is the actor who cancelled, e.g. "user:42"
+	CancelledBy string      `json:"cancelled_by"`
+	Items       []OrderItem `json:"items"`
+}
+
+// OrderStockRejectedEvent lists the items of a placed order whose stock could
+// not be taken, so order-service can cancel the order
+type OrderStockRejectedEvent struct {
+	OrderID int                 `json:"order_id"`
+	UserID  int                 `json:"user_id"`
+	Items   []RejectedOrderItem `json:"items"`
+}
+
+// Reasons the stock of an order item could not be taken
+const (
+	RejectReasonInsufficientStock = "insufficient_stock"
+	RejectReasonProductNotFound   = "product_not_found"
+	RejectReasonFailed            = "failed"
+)
+
+type RejectedOrderItem struct {
+	ProductID int    `json:"product_id"`
+	Quantity  int    `json:"quantity"`
+	Reason    string `json:"reason"`
+}
+
+// ReturnInspectedEvent lists the products of an order return to put back
+// into stock
+type ReturnInspectedEvent struct {
+	ReturnID int         `json:"return_id"`
+	OrderID  int         `json:"order_id"`
+	UserID   int         `json:"user_id"`
+	Items    []OrderItem `json:"items"`
+}
+
+type ReturnRestockedEvent struct {
+	ReturnID int         `json:"return_id"`
+	OrderID  int         `json:"order_id"`
+	Items    []OrderItem `json:"items"`
+}
+
 type OrderItem struct {
 	ProductID int `json:"product_id"`
 	Quantity  int `json:"quantity"`
@@ -2,9 +2,12 @@ package rabbitmq
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
-	"product-service/db"
+	"product-service/inventory"
+	"product-service/metrics"
 	"product-service/models"
+	"strconv"
 
 	"github.com/streadway/amqp"
 )
@@ -17,7 +20,7 @@ func EmitProductCreated(product models.Product) {
 	}
 
 	err = Channel.Publish(
-		"",                // exchange
+		Exchange,          // exchange
 		"product_created", // routing key
 		false,             // mandatory
 		false,             // immediate
@@ -45,7 +48,7 @@ func EmitInventoryUpdated(productID int, newInventory int) {
 	}
 
 	err = Channel.Publish(
-		"",                  // exchange
+		Exchange,            // exchange
 		"inventory_updated", // routing key
 		false,               // mandatory
 		false,               // immediate
@@ -61,15 +64,74 @@ func EmitInventoryUpdated(productID int, newInventory int) {
 	}
 }
 
-func EmitProductUpdated(product models.Product) {
-	body, err := json.Marshal(product)
+// EmitStockChanged publishes the new level after a stock movement and raises
+// low_stock or out_of_stock when the movement crossed the product's threshold.
+func EmitStockChanged(movement models.StockMovement) {
+	EmitInventoryUpdated(movement.ProductID, movement.ResultingInventory)
+	metrics.ProductStock.WithLabelValues(strconv.Itoa(movement.ProductID)).Set(float64(movement.ResultingInventory))
+
+	previous := movement.ResultingInventory - movement.Change
+	current := movement.ResultingInventory
+	threshold := movement.ReorderThreshold
+	event := models.StockAlertEvent{
+		ProductID:        movement.ProductID,
+		Inventory:        current,
+		ReorderThreshold: threshold,
+	}
+
+	if current <= 0 && previous > 0 {
+		EmitStockAlert("out_of_stock", event)
+	} else if current <= threshold && previous > threshold {
+		EmitStockAlert("low_stock", event)
+	}
+}
+
+// EmitThresholdChanged raises low_stock when a new reorder threshold puts a
+// product's current stock at or below it. Out of stock does not depend on
+// the threshold, so it is only raised by stock movements.
+func EmitThresholdChanged(product models.Product, previousThreshold int) {
+	if product.Inventory > 0 && product.Inventory <= product.ReorderThreshold && product.Inventory > previousThreshold {
+		EmitStockAlert("low_stock", models.StockAlertEvent{
+			ProductID:        product.ID,
+			Inventory:        product.Inventory,
+			ReorderThreshold: product.ReorderThreshold,
+		})
+	}
+}
+
+func EmitStockAlert(alert string, event models.StockAlertEvent) {
+	body, err := json.Marshal(event)
 	if err != nil {
-		log.Printf("Failed to serialize product: %v", err)
+		log.Printf("Failed to serialize %s event: %v", alert, err)
+		return
+	}
+
+	err = Channel.Publish(
+		Exchange, // exchange
+		alert,    // routing key
+		false,    // mandatory
+		false,    // immediate
+		amqp.Publishing{
+			ContentType:  "application/json",
+			Body:         body,
+			DeliveryMode: amqp.Persistent,
+		})
+	if err != nil {
+		log.Printf("Failed to publish %s event: %v", alert, err)
+	} else {
+		log.Printf("Stock Alert Event %s emitted: %s", alert, body)
+	}
+}
+
+func EmitProductUpdated(event models.ProductUpdatedEvent) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to serialize product updated event: %v", err)
 		return
 	}
 
 	err = Channel.Publish(
-		"",                // exchange
+		Exchange,          // exchange
 		"product_updated", // routing key
 		false,             // mandatory
 		false,             // immediate
@@ -96,7 +158,7 @@ func EmitProductDeleted(productID int) {
 	}
 
 	err = Channel.Publish(
-		"",                // exchange
+		Exchange,          // exchange
 		"product_deleted", // routing key
 		false,             // mandatory
 		false,             // immediate
@@ -112,6 +174,30 @@ func EmitProductDeleted(productID int) {
 	}
 }
 
+func EmitProductRestored(product models.Product) {
+	body, err := json.Marshal(product)
+	if err != nil {
+		log.Printf("Failed to serialize product: %v", err)
+		return
+	}
+
+	err = Channel.Publish(
+		Exchange,           // exchange
+		"product_restored", // routing key
+		false,              // mandatory
+		false,              // immediate
+		amqp.Publishing{
+			ContentType:  "application/json",
+			Body:         body,
+			DeliveryMode: amqp.Persistent,
+		})
+	if err != nil {
+		log.Printf("Failed to publish product_restored event: %v", err)
+	} else {
+		log.Printf("Product Restored Event emitted: %s", body)
+	}
+}
+
 func ListenForOrderPlacedEvents() {
 	msgs, err := Channel.Consume(
 		"order_placed", // queue
@@ -141,18 +227,193 @@ func ListenForOrderPlacedEvents() {
 	}()
 }
 
+// updateInventory takes the stock of a placed order. Items that are not in
+// stock are reported with order_stock_rejected; the stock already taken is
+// returned once order-service cancels the order.
 func updateInventory(orderEvent models.OrderPlacedEvent) {
+	rejected := models.OrderStockRejectedEvent{
+		OrderID: orderEvent.OrderID,
+		UserID:  orderEvent.UserID,
+		Items:   []models.RejectedOrderItem{},
+	}
 	for _, item := range orderEvent.Items {
-		// Modify the query to return the updated inventory after the update
-		query := `UPDATE products SET inventory = inventory - $1 WHERE id = $2 RETURNING inventory`
+		orderID := orderEvent.OrderID
+		movement, err := inventory.ApplyMovement(models.StockMovement{
+			ProductID: item.ProductID,
+			Change:    -item.Quantity,
+			Reason:    models.MovementReasonOrder,
+			Actor:     fmt.Sprintf("user:%d", orderEvent.UserID),
+			OrderID:   &orderID,
+		})
+		if err != nil {
+			// No stock was taken for the item, so the order cannot be fulfilled
+			reason := models.RejectReasonFailed
+			switch err {
+			case inventory.ErrInsufficientStock:
+				reason = models.RejectReasonInsufficientStock
+			case inventory.ErrProductNotFound:
+				reason = models.RejectReasonProductNotFound
+			}
+			log.Printf("Failed to take stock of product %d for order %d: %v", item.ProductID, orderID, err)
+			rejected.Items = append(rejected.Items, models.RejectedOrderItem{
+				ProductID: item.ProductID,
+				Quantity:  item.Quantity,
+				Reason:    reason,
+			})
+			continue
+		}
+		EmitStockChanged(movement)
+		log.Printf("Inventory updated for product %d, new inventory: %d", item.ProductID, movement.ResultingInventory)
+	}
+
+	if len(rejected.Items) > 0 {
+		EmitOrderStockRejected(rejected)
+	}
+}
+
+func EmitOrderStockRejected(event models.OrderStockRejectedEvent) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to serialize order stock rejected event: %v", err)
+		return
+	}
+
+	err = Channel.Publish(
+		Exchange,               // exchange
+		"order_stock_rejected", // routing key
+		false,                  // mandatory
+		false,                  // immediate
+		amqp.Publishing{
+			ContentType:  "application/json",
+			Body:         body,
+			DeliveryMode: amqp.Persistent,
+		})
+	if err != nil {
+		log.Printf("Failed to publish order_stock_rejected event: %v", err)
+	} else {
+		log.Printf("Order Stock Rejected Event emitted: %s", body)
+	}
+}
+
+func ListenForOrderCancelledEvents() {
+	msgs, err := Channel.Consume(
+		"order_cancelled", // queue
+		"",                // consumer
+		true,              // auto-ack
+		false,             // exclusive
+		false,             // no-local
+		false,             // no-wait
+		nil,               // args
+	)
+	if err != nil {
+		log.Fatalf("Failed to register a consumer: %v", err)
+	}
+
+	go func() {
+		for d := range msgs {
+			var cancelEvent models.OrderCancelledEvent
+			err := json.Unmarshal(d.Body, &cancelEvent)
+			if err != nil {
+				log.Printf("Failed to parse order cancelled event: %v", err)
+				continue
+			}
+			log.Printf("Received Order Cancelled Event: %+v", cancelEvent)
+			restoreInventory(cancelEvent)
+		}
+	}()
+}
 
-		var updatedInventory int
-		err := db.DB.QueryRow(query, item.Quantity, item.ProductID).Scan(&updatedInventory)
+// restoreInventory returns the stock taken by a cancelled order
+func restoreInventory(cancelEvent models.OrderCancelledEvent) {
+	for _, item := range cancelEvent.Items {
+		movement, returned, err := inventory.ReturnOrderStock(cancelEvent.OrderID, item.ProductID,
+			cancelEvent.CancelledBy, cancelEvent.Reason)
 		if err != nil {
-			log.Printf("Failed to update inventory for product %d: %v", item.ProductID, err)
-		} else {
-			EmitInventoryUpdated(item.ProductID, updatedInventory)
-			log.Printf("Inventory updated for product %d, new inventory: %d", item.ProductID, updatedInventory)
+			log.Printf("Failed to restore inventory for product %d: %v", item.ProductID, err)
+		} else if returned {
+			EmitStockChanged(movement)
+			log.Printf("Inventory restored for product %d, new inventory: %d", item.ProductID, movement.ResultingInventory)
 		}
 	}
 }
+
+func ListenForReturnInspectedEvents() {
+	msgs, err := Channel.Consume(
+		"return_inspected", // queue
+		"",                 // consumer
+		true,               // auto-ack
+		false,              // exclusive
+		false,              // no-local
+		false,              // no-wait
+		nil,                // args
+	)
+	if err != nil {
+		log.Fatalf("Failed to register a consumer: %v", err)
+	}
+
+	go func() {
+		for d := range msgs {
+			var returnEvent models.ReturnInspectedEvent
+			err := json.Unmarshal(d.Body, &returnEvent)
+			if err != nil {
+				log.Printf("Failed to parse return inspected event: %v", err)
+				continue
+			}
+			log.Printf("Received Return Inspected Event: %+v", returnEvent)
+			restockReturn(returnEvent)
+		}
+	}()
+}
+
+// restockReturn puts returned items back into stock and confirms what was
+// restocked with a return_restocked event
+func restockReturn(returnEvent models.ReturnInspectedEvent) {
+	restocked := models.ReturnRestockedEvent{
+		ReturnID: returnEvent.ReturnID,
+		OrderID:  returnEvent.OrderID,
+		Items:    []models.OrderItem{},
+	}
+	for _, item := range returnEvent.Items {
+		// Returns are inspected by the admin
+		movement, returned, err := inventory.RestockReturn(returnEvent.ReturnID, returnEvent.OrderID, item.ProductID, item.Quantity, "admin")
+		if err != nil {
+			log.Printf("Failed to restock product %d for return %d: %v", item.ProductID, returnEvent.ReturnID, err)
+			continue
+		}
+		if returned {
+			EmitStockChanged(movement)
+			log.Printf("Inventory restocked for product %d, new inventory: %d", item.ProductID, movement.ResultingInventory)
+		}
+		// Already restocked items are confirmed again in case the first
+		// confirmation was lost
+		restocked.Items = append(restocked.Items, item)
+	}
+
+	if len(restocked.Items) > 0 {
+		EmitReturnRestocked(restocked)
+	}
+}
+
+func EmitReturnRestocked(event models.ReturnRestockedEvent) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to serialize return restocked event: %v", err)
+		return
+	}
+
+	err = Channel.Publish(
+		Exchange,           // exchange
+		"return_restocked", // routing key
+		false,              // mandatory
+		false,              // immediate
+		amqp.Publishing{
+			ContentType:  "application/json",
+			Body:         body,
+			DeliveryMode: amqp.Persistent,
+		})
+	if err != nil {
+		log.Printf("Failed to publish return_restocked event: %v", err)
+	} else {
+		log.Printf("Return Restocked Event emitted: %s", body)
+	}
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+)
+
+// BlobStore stores binary objects such as product images under string keys
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Delete(ctx context.Context, key string) error
+	// URL returns the public URL an object can be fetched from
+	URL(key string) string
+}
+
+var Store BlobStore
+
+// Init selects the blob store from STORAGE_BACKEND ("local" or "s3")
+func Init() {
+	switch backend := getEnv("STORAGE_BACKEND", "local"); backend {
+	case "local":
+		Store = NewLocalStore(
+			getEnv("MEDIA_DIR", "./media"),
+			getEnv("MEDIA_BASE_URL", "http://localhost:8082/media"),
+		)
+	case "s3":
+		store, err := NewS3Store(S3Config{
+			Endpoint:  getEnv("S3_ENDPOINT", "localhost:9000"),
+			AccessKey: getEnv("S3_ACCESS_KEY", ""),
+			SecretKey: getEnv("S3_SECRET_KEY", ""),
+			Bucket:    getEnv("S3_BUCKET", "product-media"),
+			Region:    getEnv("S3_REGION", ""),
+			UseSSL:    getEnv("S3_USE_SSL", "false") == "true",
+			PublicURL: getEnv("S3_PUBLIC_URL", ""),
+		})
+		if err != nil {
+			log.Fatalf("Failed to initialize S3 storage: %v", err)
+		}
+		Store = store
+	default:
+		log.Fatalf("Unknown STORAGE_BACKEND %q", backend)
+	}
+	log.Printf("Using %s blob storage", getEnv("STORAGE_BACKEND", "local"))
+}
+
+func getEnv(key, defaultValue string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
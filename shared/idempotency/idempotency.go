@@ -0,0 +1,150 @@
+// Package idempotency lets clients retry mutating requests safely with an
+// Idempotency-Key header. It is shared by user_service, product-service and
+// order-service, each of which keeps its keys in its own database.
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Header is the request header carrying the client's idempotency key
+const Header = "Idempotency-Key"
+
+// Record is what a store keeps for a key. StatusCode is 0 while the request
+// that claimed the key is in progress.
+type Record struct {
+	RequestHash  string
+	StatusCode   int
+	ResponseBody []byte
+}
+
+// Store keeps the idempotency keys. Keys are scoped, e.g. to a user, and
+// forgotten after a retention period.
+type Store interface {
+	// Claim records a key for a request, reporting false if it is taken
+	Claim(scope, key, requestHash string) (bool, error)
+	// Lookup returns the record of a taken key
+	Lookup(scope, key string) (Record, error)
+	// Complete stores the response of the request that claimed a key
+	Complete(scope, key string, statusCode int, responseBody []byte) error
+	// Release forgets a key, so the request can be retried
+	Release(scope, key string) error
+}
+
+var store Store
+
+// UseStore sets the store the middleware keeps keys in. Init sets it to the
+// service's database.
+func UseStore(s Store) {
+	store = s
+}
+
+// responseRecorder keeps a copy of the response body for storage
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(data string) (int, error) {
+	w.body.WriteString(data)
+	return w.ResponseWriter.WriteString(data)
+}
+
+// Middleware makes a mutating endpoint safe to retry. The first request with
+// a given Idempotency-Key runs normally and its response is stored; retries
+// with the same key and body get that response replayed, while reuse of the
+// key for a different request is rejected with 422. Keys are scoped to the
+// authenticated user, so it must run after authentication. Anonymous keys are
+// scoped to the request itself: behind the gateway every anonymous client has
+// the same address, so two clients using the same key must not share it.
+func Middleware(c *gin.Context) {
+	key := c.GetHeader(Header)
+	if key == "" {
+		c.Next()
+		return
+	}
+	if len(key) > 255 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key must be at most 255 characters"})
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	// The actual path is hashed rather than the route, so the same key cannot
+	// be replayed against another resource, e.g. another order's /pay
+	fingerprint := sha256.Sum256(append([]byte(c.Request.Method+" "+c.Request.URL.Path+"?"+c.Request.URL.RawQuery+"\n"), body...))
+	requestHash := hex.EncodeToString(fingerprint[:])
+
+	scope := ""
+	if userID, exists := c.Get("user_id"); exists {
+		scope = fmt.Sprintf("user:%v", userID)
+	} else if username, exists := c.Get("username"); exists {
+		scope = fmt.Sprintf("username:%v", username)
+	} else {
+		scope = "anonymous:" + requestHash
+	}
+
+	claimed, err := store.Claim(scope, key, requestHash)
+	if err != nil {
+		log.Printf("Failed to claim idempotency key %s: %v", key, err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to record idempotency key"})
+		return
+	}
+	if !claimed {
+		replay(c, scope, key, requestHash)
+		return
+	}
+
+	recorder := &responseRecorder{ResponseWriter: c.Writer}
+	c.Writer = recorder
+	c.Next()
+
+	// Server errors are not remembered so the client can retry them
+	if status := recorder.Status(); status >= http.StatusInternalServerError {
+		err = store.Release(scope, key)
+	} else {
+		err = store.Complete(scope, key, status, recorder.body.Bytes())
+	}
+	if err != nil {
+		log.Printf("Failed to store idempotent response for key %s: %v", key, err)
+	}
+}
+
+// replay answers a request whose key was already used
+func replay(c *gin.Context, scope, key, requestHash string) {
+	record, err := store.Lookup(scope, key)
+	if err != nil {
+		log.Printf("Failed to look up idempotency key %s: %v", key, err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up idempotency key"})
+		return
+	}
+
+	switch {
+	case record.RequestHash != requestHash:
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used for a different request"})
+	case record.StatusCode == 0:
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still in progress"})
+	default:
+		c.Header("Idempotent-Replayed", "true")
+		c.Data(record.StatusCode, "application/json; charset=utf-8", record.ResponseBody)
+		c.Abort()
+	}
+}
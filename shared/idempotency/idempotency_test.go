@@ -0,0 +1,213 @@
+package idempotency
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// memoryStore keeps the keys in memory for the tests
+type memoryStore struct {
+	mu      sync.Mutex
+	records map[string]Record
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{records: map[string]Record{}}
+}
+
+func (s *memoryStore) Claim(scope, key, requestHash string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, taken := s.records[scope+"\n"+key]; taken {
+		return false, nil
+	}
+	s.records[scope+"\n"+key] = Record{RequestHash: requestHash}
+	return true, nil
+}
+
+func (s *memoryStore) Lookup(scope, key string) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.records[scope+"\n"+key], nil
+}
+
+func (s *memoryStore) Complete(scope, key string, statusCode int, responseBody []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record := s.records[scope+"\n"+key]
+	record.StatusCode = statusCode
+	record.ResponseBody = responseBody
+	s.records[scope+"\n"+key] = record
+	return nil
+}
+
+func (s *memoryStore) Release(scope, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, scope+"\n"+key)
+	return nil
+}
+
+// request is one call to the test endpoint
+type request struct {
+	user   string
+	key    string
+	body   string
+	status int
+	replay bool
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name     string
+		status   int
+		requests []request
+		handled  int
+	}{
+		{
+			name: "retry replays the stored response",
+			requests: []request{
+				{user: "1", key: "abc", body: `{"amount":10}`, status: http.StatusCreated},
+				{user: "1", key: "abc", body: `{"amount":10}`, status: http.StatusCreated, replay: true},
+			},
+			handled: 1,
+		},
+		{
+			name: "different request under the same key is rejected",
+			requests: []request{
+				{user: "1", key: "abc", body: `{"amount":10}`, status: http.StatusCreated},
+				{user: "1", key: "abc", body: `{"amount":20}`, status: http.StatusUnprocessableEntity},
+			},
+			handled: 1,
+		},
+		{
+			name:   "server error is not remembered",
+			status: http.StatusInternalServerError,
+			requests: []request{
+				{user: "1", key: "abc", body: `{"amount":10}`, status: http.StatusInternalServerError},
+				{user: "1", key: "abc", body: `{"amount":10}`, status: http.StatusInternalServerError},
+			},
+			handled: 2,
+		},
+		{
+			name: "keys are scoped to the user",
+			requests: []request{
+				{user: "1", key: "abc", body: `{"amount":10}`, status: http.StatusCreated},
+				{user: "2", key: "abc", body: `{"amount":10}`, status: http.StatusCreated},
+			},
+			handled: 2,
+		},
+		{
+			name: "anonymous clients do not share a key",
+			requests: []request{
+				{key: "abc", body: `{"amount":10}`, status: http.StatusCreated},
+				{key: "abc", body: `{"amount":20}`, status: http.StatusCreated},
+			},
+			handled: 2,
+		},
+		{
+			name: "requests without a key always run",
+			requests: []request{
+				{user: "1", body: `{"amount":10}`, status: http.StatusCreated},
+				{user: "1", body: `{"amount":10}`, status: http.StatusCreated},
+			},
+			handled: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memory := newMemoryStore()
+			UseStore(memory)
+			t.Cleanup(func() { UseStore(nil) })
+
+			handled := 0
+			status := tt.status
+			if status == 0 {
+				status = http.StatusCreated
+			}
+			router := newRouter(func(c *gin.Context) {
+				handled++
+				c.JSON(status, gin.H{"attempt": handled})
+			})
+
+			var first string
+			for i, req := range tt.requests {
+				w := serve(router, req)
+
+				if w.Code != req.status {
+					t.Errorf("request %d: status = %d, want %d", i+1, w.Code, req.status)
+				}
+				if replayed := w.Header().Get("Idempotent-Replayed") == "true"; replayed != req.replay {
+					t.Errorf("request %d: replayed = %v, want %v", i+1, replayed, req.replay)
+				}
+				if i == 0 {
+					first = w.Body.String()
+				} else if req.replay && w.Body.String() != first {
+					t.Errorf("request %d: body = %s, want %s", i+1, w.Body.String(), first)
+				}
+			}
+			if handled != tt.handled {
+				t.Errorf("handler ran %d times, want %d", handled, tt.handled)
+			}
+		})
+	}
+}
+
+func TestMiddlewareRequestInFlight(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	UseStore(newMemoryStore())
+	t.Cleanup(func() { UseStore(nil) })
+
+	req := request{user: "1", key: "abc", body: `{"amount":10}`}
+	var router *gin.Engine
+	var retry *httptest.ResponseRecorder
+	router = newRouter(func(c *gin.Context) {
+		// The client retries before the first request has finished
+		if retry == nil {
+			retry = serve(router, req)
+		}
+		c.JSON(http.StatusCreated, gin.H{})
+	})
+
+	if w := serve(router, req); w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusCreated)
+	}
+	if retry.Code != http.StatusConflict {
+		t.Errorf("retry status = %d, want %d", retry.Code, http.StatusConflict)
+	}
+}
+
+// Helper function to build a router running the middleware for a user taken
+// from the X-Test-User header
+func newRouter(handler gin.HandlerFunc) *gin.Engine {
+	router := gin.New()
+	router.POST("/payments", func(c *gin.Context) {
+		if user := c.GetHeader("X-Test-User"); user != "" {
+			c.Set("user_id", user)
+		}
+		c.Next()
+	}, Middleware, handler)
+	return router
+}
+
+// Helper function to send a request to the router
+func serve(router *gin.Engine, req request) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(req.body))
+	if req.user != "" {
+		r.Header.Set("X-Test-User", req.user)
+	}
+	if req.key != "" {
+		r.Header.Set(Header, req.key)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
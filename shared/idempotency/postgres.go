@@ -0,0 +1,108 @@
+package idempotency
+
+import (
+	"database/sql"
+	"log"
+	"time"
+)
+
+const (
+	// Keys are remembered for a day; afterwards the key may be reused
+	retention = "24 hours"
+	// A claim without a response after this long belongs to a request that
+	// died, e.g. in a crash, and is taken over by the next retry
+	claimTimeout = "5 minutes"
+	// Expired keys are deleted this often
+	purgeInterval = time.Hour
+)
+
+// PostgresStore keeps the keys in the idempotency_keys table
+type PostgresStore struct {
+	DB *sql.DB
+}
+
+// Init creates the idempotency_keys table in the service's database, makes
+// it the middleware's store and starts purging expired keys. Keys are scoped
+// to a user, or to the request for anonymous callers, and request_hash
+// identifies the request a key was first used for. status_code and
+// response_body stay NULL while that request is in progress.
+func Init(db *sql.DB) {
+	_, err := db.Exec(`
+    CREATE TABLE IF NOT EXISTS idempotency_keys (
+        scope VARCHAR(100) NOT NULL,
+        key VARCHAR(255) NOT NULL,
+        request_hash VARCHAR(64) NOT NULL,
+        status_code INT,
+        response_body BYTEA,
+        created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+        PRIMARY KEY (scope, key)
+    );
+    CREATE INDEX IF NOT EXISTS idempotency_keys_created_at_idx ON idempotency_keys (created_at);`)
+	if err != nil {
+		log.Fatalf("Failed to create idempotency_keys table: %v", err)
+	}
+
+	postgresStore := &PostgresStore{DB: db}
+	UseStore(postgresStore)
+
+	go func() {
+		for {
+			postgresStore.purgeExpired()
+			time.Sleep(purgeInterval)
+		}
+	}()
+}
+
+// Claim forgets the key if it expired or its claim was abandoned, then
+// claims it
+func (s *PostgresStore) Claim(scope, key, requestHash string) (bool, error) {
+	_, err := s.DB.Exec(`DELETE FROM idempotency_keys WHERE scope = $1 AND key = $2
+	AND (created_at < CURRENT_TIMESTAMP - INTERVAL '`+retention+`'
+	OR (status_code IS NULL AND created_at < CURRENT_TIMESTAMP - INTERVAL '`+claimTimeout+`'))`, scope, key)
+	if err != nil {
+		log.Printf("Failed to expire idempotency key: %v", err)
+	}
+
+	result, err := s.DB.Exec(`INSERT INTO idempotency_keys (scope, key, request_hash) VALUES ($1, $2, $3)
+	ON CONFLICT DO NOTHING`, scope, key, requestHash)
+	if err != nil {
+		return false, err
+	}
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return claimed > 0, nil
+}
+
+func (s *PostgresStore) Lookup(scope, key string) (Record, error) {
+	var record Record
+	var statusCode sql.NullInt64
+	err := s.DB.QueryRow(`SELECT request_hash, status_code, response_body FROM idempotency_keys WHERE scope = $1 AND key = $2`,
+		scope, key).Scan(&record.RequestHash, &statusCode, &record.ResponseBody)
+	record.StatusCode = int(statusCode.Int64)
+	return record, err
+}
+
+func (s *PostgresStore) Complete(scope, key string, statusCode int, responseBody []byte) error {
+	_, err := s.DB.Exec(`UPDATE idempotency_keys SET status_code = $1, response_body = $2 WHERE scope = $3 AND key = $4`,
+		statusCode, responseBody, scope, key)
+	return err
+}
+
+func (s *PostgresStore) Release(scope, key string) error {
+	_, err := s.DB.Exec(`DELETE FROM idempotency_keys WHERE scope = $1 AND key = $2`, scope, key)
+	return err
+}
+
+// Helper function to delete the keys past their retention
+func (s *PostgresStore) purgeExpired() {
+	result, err := s.DB.Exec(`DELETE FROM idempotency_keys WHERE created_at < CURRENT_TIMESTAMP - INTERVAL '` + retention + `'`)
+	if err != nil {
+		log.Printf("Failed to purge expired idempotency keys: %v", err)
+		return
+	}
+	if purged, _ := result.RowsAffected(); purged > 0 {
+		log.Printf("Purged %d expired idempotency keys", purged)
+	}
+}
@@ -55,10 +55,27 @@ func createTable() {
         email VARCHAR(100) UNIQUE NOT NULL,
         password VARCHAR(255) NOT NULL,
         created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-    );`
+    );
+    CREATE TABLE IF NOT EXISTS addresses (
+        id SERIAL PRIMARY KEY,
+        user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+        label VARCHAR(50) NOT NULL DEFAULT '',
+        full_name VARCHAR(100) NOT NULL,
+        line1 VARCHAR(255) NOT NULL,
+        line2 VARCHAR(255) NOT NULL DEFAULT '',
+        city VARCHAR(100) NOT NULL,
+        region VARCHAR(100) NOT NULL DEFAULT '',
+        postal_code VARCHAR(20) NOT NULL,
+        country VARCHAR(2) NOT NULL,
+        phone VARCHAR(30) NOT NULL DEFAULT '',
+        is_default BOOLEAN NOT NULL DEFAULT FALSE,
+        created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+    );
+    CREATE INDEX IF NOT EXISTS addresses_user_id_idx ON addresses (user_id);
+    CREATE UNIQUE INDEX IF NOT EXISTS addresses_one_default_idx ON addresses (user_id) WHERE is_default;`
 	_, err := DB.Exec(query)
 	if err != nil {
-		log.Fatalf("Failed to create users table: %v", err)
+		log.Fatalf("Failed to create user service tables: %v", err)
 	}
 }
 
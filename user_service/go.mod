@@ -9,6 +9,7 @@ require (
 	github.com/prometheus/client_golang v1.20.4
 	github.com/streadway/amqp v1.1.0
 	golang.org/x/crypto v0.27.0
+	shared v0.0.0
 )
 
 require (
@@ -46,3 +47,5 @@ require (
 	google.golang.org/protobuf v1.34.2 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace shared => ../shared
@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"user_service/db"
+	"user_service/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const addressColumns = `id, user_id, label, full_name, line1, line2, city, region, postal_code, country, phone, is_default, created_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAddress(row rowScanner) (models.Address, error) {
+	var address models.Address
+	err := row.Scan(&address.ID, &address.UserID, &address.Label, &address.FullName, &address.Line1, &address.Line2,
+		&address.City, &address.Region, &address.PostalCode, &address.Country, &address.Phone, &address.IsDefault, &address.CreatedAt)
+	return address, err
+}
+
+func GetAddresses(c *gin.Context) {
+	// Authentication
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	query := `SELECT ` + addressColumns + ` FROM addresses WHERE user_id = $1 ORDER BY is_default DESC, id`
+	rows, err := db.DB.Query(query, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve addresses"})
+		return
+	}
+	defer rows.Close()
+
+	addresses := []models.Address{}
+	for rows.Next() {
+		address, err := scanAddress(rows)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan address"})
+			return
+		}
+		addresses = append(addresses, address)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"addresses": addresses})
+}
+
+// GetAddressByID returns one of the user's addresses. The special ID
+// "default" returns the default address.
+func GetAddressByID(c *gin.Context) {
+	// Authentication
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var row *sql.Row
+	if c.Param("id") == "default" {
+		query := `SELECT ` + addressColumns + ` FROM addresses WHERE user_id = $1 AND is_default`
+		row = db.DB.QueryRow(query, userID)
+	} else {
+		addressID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid address ID"})
+			return
+		}
+		query := `SELECT ` + addressColumns + ` FROM addresses WHERE id = $1 AND user_id = $2`
+		row = db.DB.QueryRow(query, addressID, userID)
+	}
+
+	address, err := scanAddress(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Address not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve address"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"address": address})
+}
+
+// CreateAddress adds an address to the user's address book. The first
+// address always becomes the default.
+func CreateAddress(c *gin.Context) {
+	// Authentication
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var input models.Address
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	input.Country = strings.ToUpper(input.Country)
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	var count int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM addresses WHERE user_id = $1`, userID).Scan(&count)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create address"})
+		return
+	}
+	if count == 0 {
+		input.IsDefault = true
+	}
+	if input.IsDefault {
+		if _, err := tx.Exec(`UPDATE addresses SET is_default = FALSE WHERE user_id = $1 AND is_default`, userID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create address"})
+			return
+		}
+	}
+
+	query := `INSERT INTO addresses (user_id, label, full_name, line1, line2, city, region, postal_code, country, phone, is_default)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING ` + addressColumns
+	address, err := scanAddress(tx.QueryRow(query, userID, input.Label, input.FullName, input.Line1, input.Line2,
+		input.City, input.Region, input.PostalCode, input.Country, input.Phone, input.IsDefault))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create address"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Address created successfully", "address": address})
+}
+
+// UpdateAddress replaces an address. Unsetting is_default is ignored; make
+// another address the default instead.
+func UpdateAddress(c *gin.Context) {
+	// Authentication
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	addressID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid address ID"})
+		return
+	}
+
+	var input models.Address
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	input.Country = strings.ToUpper(input.Country)
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	if input.IsDefault {
+		_, err := tx.Exec(`UPDATE addresses SET is_default = FALSE WHERE user_id = $1 AND is_default AND id <> $2`, userID, addressID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update address"})
+			return
+		}
+	}
+
+	query := `UPDATE addresses SET label = $1, full_name = $2, line1 = $3, line2 = $4, city = $5, region = $6,
+	postal_code = $7, country = $8, phone = $9, is_default = is_default OR $10
+	WHERE id = $11 AND user_id = $12 RETURNING ` + addressColumns
+	address, err := scanAddress(tx.QueryRow(query, input.Label, input.FullName, input.Line1, input.Line2, input.City,
+		input.Region, input.PostalCode, input.Country, input.Phone, input.IsDefault, addressID, userID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Address not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update address"})
+		}
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Address updated successfully", "address": address})
+}
+
+// DeleteAddress removes an address. Orders keep their own copy, so this never
+// affects order history. If the default goes, the oldest remaining address
+// takes its place.
+func DeleteAddress(c *gin.Context) {
+	// Authentication
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	addressID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid address ID"})
+		return
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	var wasDefault bool
+	query := `DELETE FROM addresses WHERE id = $1 AND user_id = $2 RETURNING is_default`
+	err = tx.QueryRow(query, addressID, userID).Scan(&wasDefault)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Address not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete address"})
+		}
+		return
+	}
+
+	if wasDefault {
+		query = `UPDATE addresses SET is_default = TRUE
+		WHERE id = (SELECT id FROM addresses WHERE user_id = $1 ORDER BY id LIMIT 1)`
+		if _, err := tx.Exec(query, userID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete address"})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Address deleted successfully"})
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
 )
 
 func RegisterUser(c *gin.Context) {
@@ -53,8 +54,8 @@ func LoginUser(c *gin.Context) {
 	}
 
 	var user models.User
-	query := `SELECT id, username, password FROM users WHERE username = $1`
-	err := db.DB.QueryRow(query, input.Username).Scan(&user.ID, &user.Username, &user.Password)
+	query := `SELECT id, username, email, password, created_at FROM users WHERE username = $1`
+	err := db.DB.QueryRow(query, input.Username).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.CreatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
@@ -75,7 +76,8 @@ func LoginUser(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": token})
+	user.Password = "" // Do not expose password
+	c.JSON(http.StatusOK, gin.H{"token": token, "user": user})
 }
 
 func UpdateProfile(c *gin.Context) {
@@ -102,7 +104,10 @@ func UpdateProfile(c *gin.Context) {
 		return
 	}
 
-	// Only allow updates to own profile
+	// Only allow updates to own profile; the username defaults to the caller's
+	if input.Username == "" {
+		input.Username = claims.Username
+	}
 	if claims.Username != input.Username {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot update another user's profile"})
 		return
@@ -117,7 +122,7 @@ func UpdateProfile(c *gin.Context) {
 
 	input.Password = "" // Do not expose password
 	rabbitmq.EmitUserProfileUpdated(input)
-	c.JSON(http.StatusOK, gin.H{"message": "Profile updated successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Profile updated successfully", "user": input})
 }
 
 func Authenticate(c *gin.Context) {
@@ -146,7 +151,8 @@ func Authenticate(c *gin.Context) {
 		return
 	}
 
-	// Store username in context
+	// Store user information in context
+	c.Set("user_id", claims.UserID)
 	c.Set("username", claims.Username)
 	c.Next()
 }
@@ -165,8 +171,19 @@ func GetAllUsers(c *gin.Context) {
 	// 	return
 	// }
 
+	// ?ids=1,2,3 fetches just those users, for batched lookups
 	query := `SELECT id, username, email, created_at FROM users`
-	rows, err := db.DB.Query(query)
+	args := []interface{}{}
+	if idsParam := c.Query("ids"); idsParam != "" {
+		ids, err := parseIDList(idsParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user IDs"})
+			return
+		}
+		query += ` WHERE id = ANY($1)`
+		args = append(args, pq.Array(ids))
+	}
+	rows, err := db.DB.Query(query, args...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve users"})
 		return
@@ -216,3 +233,16 @@ func GetUserByID(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"user": user})
 }
+
+// parseIDList parses a comma-separated list of IDs
+func parseIDList(param string) ([]int, error) {
+	ids := []int{}
+	for _, part := range strings.Split(param, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"shared/idempotency"
 	"user_service/db"
 	"user_service/handlers"
 	"user_service/rabbitmq"
@@ -15,6 +16,9 @@ func main() {
 	db.Init()
 	defer db.DB.Close()
 
+	// Initialize idempotency keys and purge expired ones
+	idempotency.Init(db.DB)
+
 	// Initialize RabbitMQ
 	rabbitmq.Init()
 	defer rabbitmq.Close()
@@ -41,7 +45,7 @@ func main() {
 		c.Next()
 	})
 
-	r.POST("/register", handlers.RegisterUser)
+	r.POST("/register", idempotency.Middleware, handlers.RegisterUser)
 	r.POST("/login", handlers.LoginUser)
 	r.GET("/users", handlers.GetAllUsers)
 	r.GET("/users/:id", handlers.GetUserByID)
@@ -50,6 +54,11 @@ func main() {
 	authorized := r.Group("/", handlers.Authenticate)
 	{
 		authorized.PUT("/profile", handlers.UpdateProfile)
+		authorized.GET("/addresses", handlers.GetAddresses)
+		authorized.POST("/addresses", idempotency.Middleware, handlers.CreateAddress)
+		authorized.GET("/addresses/:id", handlers.GetAddressByID)
+		authorized.PUT("/addresses/:id", handlers.UpdateAddress)
+		authorized.DELETE("/addresses/:id", handlers.DeleteAddress)
 	}
 
 	// Start server
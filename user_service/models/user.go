@@ -7,3 +7,20 @@ type User struct {
 	Password  string `json:"-"`
 	CreatedAt string `json:"created_at"`
 }
+
+// Address is a shipping address in a user's address book
+type Address struct {
+	ID         int    `json:"id"`
+	UserID     int    `json:"user_id"`
+	Label      string `json:"label"`
+	FullName   string `json:"full_name" binding:"required"`
+	Line1      string `json:"line1" binding:"required"`
+	Line2      string `json:"line2"`
+	City       string `json:"city" binding:"required"`
+	Region     string `json:"region"`
+	PostalCode string `json:"postal_code" binding:"required"`
+	Country    string `json:"country" binding:"required,len=2"`
+	Phone      string `json:"phone"`
+	IsDefault  bool   `json:"is_default"`
+	CreatedAt  string `json:"created_at"`
+}
@@ -16,7 +16,7 @@ func EmitUserRegistered(user models.User) {
 	}
 
 	err = Channel.Publish(
-		"",                // exchange
+		Exchange,          // exchange
 		"user_registered", // routing key
 		false,             // mandatory
 		false,             // immediate
@@ -39,7 +39,7 @@ func EmitUserProfileUpdated(user models.User) {
 	}
 
 	err = Channel.Publish(
-		"",                     // exchange
+		Exchange,               // exchange
 		"user_profile_updated", // routing key
 		false,                  // mandatory
 		false,                  // immediate